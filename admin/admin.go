@@ -0,0 +1,202 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/COAOX/zecrey_warrior/chat"
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/game"
+	"github.com/COAOX/zecrey_warrior/logging"
+	"github.com/COAOX/zecrey_warrior/role"
+	"github.com/topfreegames/pitaya/v2"
+	"github.com/topfreegames/pitaya/v2/component"
+	"go.uber.org/zap"
+)
+
+const (
+	maxRoundDuration = 24 * 60 * 60
+)
+
+var (
+	ErrUnauthorized    = errors.New("unauthorized")
+	ErrInvalidDuration = errors.New("invalid round duration")
+)
+
+// Room is the operator component, it controls the running rounds.
+// Every handler but Auth requires the session to be a moderator, see role.Moderate.
+type Room struct {
+	component.Base
+	app  pitaya.Pitaya
+	cfg  *config.Config
+	game *game.Game
+	chat *chat.Room
+
+	reload func() error
+}
+
+func RegistRoom(app pitaya.Pitaya, cfg *config.Config, game *game.Game, chat *chat.Room, reload func() error) *Room {
+	r := &Room{
+		app:    app,
+		cfg:    cfg,
+		game:   game,
+		chat:   chat,
+		reload: reload,
+	}
+	app.Register(r,
+		component.WithName(config.AdminRoomName),
+		component.WithNameFunc(strings.ToLower),
+	)
+	return r
+}
+
+type Response struct {
+	Code   int    `json:"code"`
+	Result string `json:"result"`
+}
+
+type AuthRequest struct {
+	Token string `json:"token"`
+}
+
+type DurationRequest struct {
+	Seconds int `json:"seconds"`
+}
+
+// SpawnRequest drops an item on cell X, Y, or on a random cell when they are left out.
+type SpawnRequest struct {
+	Type game.ItemType `json:"type"`
+	X    *int          `json:"x"`
+	Y    *int          `json:"y"`
+}
+
+type KickRequest struct {
+	PlayerID uint64 `json:"player_id"`
+}
+
+type StatusResponse struct {
+	Code   int              `json:"code"`
+	Result string           `json:"result"`
+	Status game.RoundStatus `json:"status"`
+}
+
+var success = &Response{Result: "success"}
+
+// Auth authenticates the session as an operator
+func (r *Room) Auth(ctx context.Context, req *AuthRequest) (*Response, error) {
+	if role.Claim(r.cfg, role.Moderator, req.Token) != nil {
+		return nil, pitaya.Error(ErrUnauthorized, "RH-401", map[string]string{"failed": "auth, invalid token"})
+	}
+	s := r.app.GetSessionFromCtx(ctx)
+	if err := role.Set(s, role.Moderator); err != nil {
+		return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "auth, set session"})
+	}
+	r.log().Info("admin session authenticated", zap.Int64("session_id", s.ID()))
+	return success, nil
+}
+
+// Pause freezes the running round
+func (r *Room) Pause(ctx context.Context) (*Response, error) {
+	return r.do(ctx, r.game.Pause)
+}
+
+// Resume continues a paused round
+func (r *Room) Resume(ctx context.Context) (*Response, error) {
+	return r.do(ctx, r.game.Resume)
+}
+
+// End settles the running round now
+func (r *Room) End(ctx context.Context) (*Response, error) {
+	return r.do(ctx, r.game.EndRound)
+}
+
+// Restart abandons the running round and starts a new one
+func (r *Room) Restart(ctx context.Context) (*Response, error) {
+	return r.do(ctx, r.game.RestartRound)
+}
+
+// Duration sets the duration of the next rounds
+func (r *Room) Duration(ctx context.Context, req *DurationRequest) (*Response, error) {
+	return r.do(ctx, func() error { return r.setDuration(req.Seconds) })
+}
+
+// Spawn drops an item in the arena
+func (r *Room) Spawn(ctx context.Context, req *SpawnRequest) (*Response, error) {
+	return r.do(ctx, func() error {
+		_, err := r.spawn(req)
+		return err
+	})
+}
+
+// Kick removes a player's ball and disconnects the player
+func (r *Room) Kick(ctx context.Context, req *KickRequest) (*Response, error) {
+	return r.do(ctx, func() error { return r.kick(req.PlayerID) })
+}
+
+// Reload reloads the config file, its settings apply from the next round
+func (r *Room) Reload(ctx context.Context) (*Response, error) {
+	return r.do(ctx, r.reload)
+}
+
+// Status reports the state of the running round
+func (r *Room) Status(ctx context.Context) (*StatusResponse, error) {
+	if !r.authorized(ctx) {
+		return nil, pitaya.Error(ErrUnauthorized, "RH-401", map[string]string{"failed": "admin, not authenticated"})
+	}
+	status, err := r.game.Status()
+	if err != nil {
+		return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "admin status", "error": err.Error()})
+	}
+	return &StatusResponse{Result: "success", Status: status}, nil
+}
+
+func (r *Room) do(ctx context.Context, f func() error) (*Response, error) {
+	if !r.authorized(ctx) {
+		return nil, pitaya.Error(ErrUnauthorized, "RH-401", map[string]string{"failed": "admin, not authenticated"})
+	}
+	if err := f(); err != nil {
+		return nil, pitaya.Error(err, "RH-400", map[string]string{"failed": "admin", "error": err.Error()})
+	}
+	return success, nil
+}
+
+// log returns the logger of the admin room, with the id of the current round.
+func (r *Room) log() *zap.Logger {
+	return zap.L().With(logging.Room(config.AdminRoomName), logging.GameID(r.game.GetGameID()))
+}
+
+func (r *Room) authorized(ctx context.Context) bool {
+	s := r.app.GetSessionFromCtx(ctx)
+	return s != nil && role.Of(s).Can(role.Moderate)
+}
+
+func (r *Room) setDuration(seconds int) error {
+	if seconds <= 0 || seconds > maxRoundDuration {
+		return ErrInvalidDuration
+	}
+	return r.game.SetNextRoundDuration(seconds)
+}
+
+// spawn drops the item of req, a coordinate left out is random like a negative one.
+func (r *Room) spawn(req *SpawnRequest) (*game.ItemObject, error) {
+	x, y := -1, -1
+	if req.X != nil {
+		x = *req.X
+	}
+	if req.Y != nil {
+		y = *req.Y
+	}
+	return r.game.SpawnItem(req.Type, x, y)
+}
+
+func (r *Room) kick(playerID uint64) error {
+	err := r.game.RemovePlayer(playerID)
+	if kicked := r.chat.Kick(playerID); kicked && err == game.ErrPlayerNotFound {
+		err = nil
+	}
+	if err == nil {
+		r.log().Info("player kicked", logging.PlayerID(playerID))
+	}
+	return err
+}
@@ -0,0 +1,217 @@
+package admin
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/COAOX/zecrey_warrior/chat"
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/game"
+	"github.com/topfreegames/pitaya/v2"
+	"github.com/topfreegames/pitaya/v2/component"
+	pitayaerrors "github.com/topfreegames/pitaya/v2/errors"
+	"github.com/topfreegames/pitaya/v2/session"
+)
+
+const testToken = "secret"
+
+// testApp serves the session of the handler and lets the chat room register,
+// the handlers under test don't call the rest of pitaya.Pitaya.
+type testApp struct {
+	pitaya.Pitaya
+	s session.Session
+}
+
+func (a *testApp) GetSessionFromCtx(context.Context) session.Session         { return a.s }
+func (a *testApp) GroupCreate(context.Context, string) error                 { return nil }
+func (a *testApp) Register(component.Component, ...component.Option)         {}
+func (a *testApp) SendKickToUsers(uids []string, _ string) ([]string, error) { return nil, nil }
+func (a *testApp) GroupBroadcast(context.Context, string, string, string, interface{}) error {
+	return nil
+}
+
+// newTestRoom returns an admin room over a running game with a ball for player 1,
+// and the number of times the config was reloaded.
+func newTestRoom(t *testing.T) (*Room, *int) {
+	cfg := &config.Config{FPS: 30, ItemFrameChance: 1 << 30, GameDuration: 600, GameRoundInterval: 600, AdminToken: testToken, FrontendType: "test"}
+	d := db.NewMemoryClient()
+	writer := db.NewWriter(d, db.WriterConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	g := game.NewGame(ctx, cfg, d, writer, func(context.Context) {}, func(context.Context) {}, func(camp game.Camp, votes int32) {})
+	g.Start()
+
+	added := make(chan struct{})
+	g.AddPlayer(1, "alice", game.BTC, func() { close(added) })
+	<-added
+
+	app := &testApp{s: session.NewSessionPool().NewSession(nil, true)}
+	reloads := 0
+	r := &Room{app: app, cfg: cfg, game: g, chat: chat.RegistRoom(app, d, writer, cfg, g)}
+	r.reload = func() error {
+		reloads++
+		if reloads > 1 {
+			return errors.New("bad config")
+		}
+		return nil
+	}
+	return r, &reloads
+}
+
+func code(err error) string {
+	var perr *pitayaerrors.Error
+	if errors.As(err, &perr) {
+		return perr.Code
+	}
+	return ""
+}
+
+func status(t *testing.T, r *Room) game.RoundStatus {
+	s, err := r.game.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestAuth(t *testing.T) {
+	r, _ := newTestRoom(t)
+	ctx := context.Background()
+	if _, err := r.Pause(ctx); code(err) != "RH-401" {
+		t.Errorf("pause before auth: %v, want RH-401", err)
+	}
+	for _, token := range []string{"", "wrong"} {
+		if _, err := r.Auth(ctx, &AuthRequest{Token: token}); code(err) != "RH-401" {
+			t.Errorf("auth with %q: %v, want RH-401", token, err)
+		}
+	}
+	if _, err := r.Status(ctx); code(err) != "RH-401" {
+		t.Errorf("status after failed auths: %v, want RH-401", err)
+	}
+	if _, err := r.Auth(ctx, &AuthRequest{Token: testToken}); err != nil {
+		t.Fatal(err)
+	}
+	if res, err := r.Status(ctx); err != nil || res.Status.Players != 1 {
+		t.Errorf("status after auth: %+v, %v", res, err)
+	}
+
+	// an empty admin token disables the role, even for an empty token
+	r.cfg.AdminToken = ""
+	if _, err := r.Auth(ctx, &AuthRequest{}); code(err) != "RH-401" {
+		t.Errorf("auth without an admin token configured: %v, want RH-401", err)
+	}
+}
+
+func TestControls(t *testing.T) {
+	r, reloads := newTestRoom(t)
+	ctx := context.Background()
+	if _, err := r.Auth(ctx, &AuthRequest{Token: testToken}); err != nil {
+		t.Fatal(err)
+	}
+	steps := []struct {
+		name   string
+		call   func(context.Context) (*Response, error)
+		code   string
+		status game.GameStatus
+	}{
+		{"resume a running round", r.Resume, "RH-400", game.GameRunning},
+		{"pause", r.Pause, "", game.GamePaused},
+		{"pause again", r.Pause, "RH-400", game.GamePaused},
+		{"resume", r.Resume, "", game.GameRunning},
+		{"kick", func(ctx context.Context) (*Response, error) { return r.Kick(ctx, &KickRequest{PlayerID: 1}) }, "", game.GameRunning},
+		{"kick unknown player", func(ctx context.Context) (*Response, error) { return r.Kick(ctx, &KickRequest{PlayerID: 1}) }, "RH-400", game.GameRunning},
+		{"reload", r.Reload, "", game.GameRunning},
+		{"failed reload", r.Reload, "RH-400", game.GameRunning},
+		{"restart", r.Restart, "", game.GameRunning},
+		{"end", r.End, "", game.GameStopped},
+		{"end during the break", r.End, "RH-400", game.GameStopped},
+		{"restart during the break", r.Restart, "RH-400", game.GameStopped},
+		{"pause during the break", r.Pause, "RH-400", game.GameStopped},
+	}
+	for _, step := range steps {
+		_, err := step.call(ctx)
+		if code(err) != step.code {
+			t.Errorf("%s: %v, want code %q", step.name, err, step.code)
+		}
+		if s := status(t, r); s.Status != step.status {
+			t.Errorf("%s: round %v, want %v", step.name, s.Status, step.status)
+		}
+	}
+	if *reloads != 2 {
+		t.Errorf("config reloaded %d times, want 2", *reloads)
+	}
+}
+
+func TestHTTP(t *testing.T) {
+	r, _ := newTestRoom(t)
+	mux := http.NewServeMux()
+	r.Routes(mux)
+	serve := func(method, path, token, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name         string
+		method, path string
+		token, body  string
+		code         int
+		status       game.GameStatus
+	}{
+		{"no token", http.MethodPost, "/admin/pause", "", "", http.StatusUnauthorized, game.GameRunning},
+		{"wrong token", http.MethodPost, "/admin/pause", "wrong", "", http.StatusUnauthorized, game.GameRunning},
+		{"wrong method", http.MethodGet, "/admin/pause", testToken, "", http.StatusMethodNotAllowed, game.GameRunning},
+		{"pause", http.MethodPost, "/admin/pause", testToken, "", http.StatusOK, game.GamePaused},
+		{"pause again", http.MethodPost, "/admin/pause", testToken, "", http.StatusConflict, game.GamePaused},
+		{"resume", http.MethodPost, "/admin/resume", testToken, "", http.StatusOK, game.GameRunning},
+		{"status", http.MethodGet, "/admin/status", testToken, "", http.StatusOK, game.GameRunning},
+		{"kick", http.MethodPost, "/admin/kick", testToken, `{"player_id": 1}`, http.StatusOK, game.GameRunning},
+		{"kick unknown player", http.MethodPost, "/admin/kick", testToken, `{"player_id": 1}`, http.StatusNotFound, game.GameRunning},
+		{"kick invalid body", http.MethodPost, "/admin/kick", testToken, `{`, http.StatusBadRequest, game.GameRunning},
+		{"reload", http.MethodPost, "/admin/reload", testToken, "", http.StatusOK, game.GameRunning},
+		{"failed reload", http.MethodPost, "/admin/reload", testToken, "", http.StatusBadRequest, game.GameRunning},
+		{"restart", http.MethodPost, "/admin/restart", testToken, "", http.StatusOK, game.GameRunning},
+		{"end", http.MethodPost, "/admin/end", testToken, "", http.StatusOK, game.GameStopped},
+		{"end during the break", http.MethodPost, "/admin/end", testToken, "", http.StatusConflict, game.GameStopped},
+		{"restart during the break", http.MethodPost, "/admin/restart", testToken, "", http.StatusConflict, game.GameStopped},
+		{"status during the break", http.MethodGet, "/admin/status", testToken, "", http.StatusOK, game.GameStopped},
+	}
+	for _, tt := range tests {
+		if w := serve(tt.method, tt.path, tt.token, tt.body); w.Code != tt.code {
+			t.Errorf("%s: %d %s, want %d", tt.name, w.Code, strings.TrimSpace(w.Body.String()), tt.code)
+		}
+		if s := status(t, r); s.Status != tt.status {
+			t.Errorf("%s: round %v, want %v", tt.name, s.Status, tt.status)
+		}
+	}
+}
+
+func TestDuration(t *testing.T) {
+	r, _ := newTestRoom(t)
+	ctx := context.Background()
+	if _, err := r.Auth(ctx, &AuthRequest{Token: testToken}); err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		seconds int
+		code    string
+		next    int
+	}{{0, "RH-400", 600}, {-1, "RH-400", 600}, {maxRoundDuration + 1, "RH-400", 600}, {120, "", 120}} {
+		if _, err := r.Duration(ctx, &DurationRequest{Seconds: tt.seconds}); code(err) != tt.code {
+			t.Errorf("duration %d: %v, want code %q", tt.seconds, err, tt.code)
+		}
+		if s := status(t, r); s.NextDuration != tt.next {
+			t.Errorf("duration %d: next round %d, want %d", tt.seconds, s.NextDuration, tt.next)
+		}
+	}
+}
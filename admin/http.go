@@ -0,0 +1,89 @@
+package admin
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/COAOX/zecrey_warrior/game"
+	"github.com/COAOX/zecrey_warrior/role"
+)
+
+// Routes registers the HTTP flavour of the admin api on mux. Requests must carry
+// the admin token as "Authorization: Bearer <token>".
+func (r *Room) Routes(mux *http.ServeMux) {
+	mux.HandleFunc("/admin/pause", r.post(func(req *http.Request) (interface{}, error) { return nil, r.game.Pause() }))
+	mux.HandleFunc("/admin/resume", r.post(func(req *http.Request) (interface{}, error) { return nil, r.game.Resume() }))
+	mux.HandleFunc("/admin/end", r.post(func(req *http.Request) (interface{}, error) { return nil, r.game.EndRound() }))
+	mux.HandleFunc("/admin/restart", r.post(func(req *http.Request) (interface{}, error) { return nil, r.game.RestartRound() }))
+	mux.HandleFunc("/admin/reload", r.post(func(req *http.Request) (interface{}, error) { return nil, r.reload() }))
+	mux.HandleFunc("/admin/duration", r.post(func(req *http.Request) (interface{}, error) {
+		var body DurationRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		return nil, r.setDuration(body.Seconds)
+	}))
+	mux.HandleFunc("/admin/items", r.post(func(req *http.Request) (interface{}, error) {
+		var body SpawnRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		return r.spawn(&body)
+	}))
+	mux.HandleFunc("/admin/kick", r.post(func(req *http.Request) (interface{}, error) {
+		var body KickRequest
+		if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+			return nil, err
+		}
+		return nil, r.kick(body.PlayerID)
+	}))
+	mux.HandleFunc("/admin/status", r.authenticate(http.MethodGet, func(req *http.Request) (interface{}, error) {
+		return r.game.Status()
+	}))
+}
+
+func (r *Room) post(f func(*http.Request) (interface{}, error)) http.HandlerFunc {
+	return r.authenticate(http.MethodPost, f)
+}
+
+func (r *Room) authenticate(method string, f func(*http.Request) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		token := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+		if role.Claim(r.cfg, role.Moderator, token) != nil {
+			writeJSON(w, http.StatusUnauthorized, Response{Code: http.StatusUnauthorized, Result: ErrUnauthorized.Error()})
+			return
+		}
+		if req.Method != method {
+			writeJSON(w, http.StatusMethodNotAllowed, Response{Code: http.StatusMethodNotAllowed, Result: "method not allowed"})
+			return
+		}
+		v, err := f(req)
+		if err != nil {
+			writeJSON(w, statusOf(err), Response{Code: statusOf(err), Result: err.Error()})
+			return
+		}
+		if v == nil {
+			v = success
+		}
+		writeJSON(w, http.StatusOK, v)
+	}
+}
+
+func statusOf(err error) int {
+	switch err {
+	case game.ErrControlTimeout:
+		return http.StatusServiceUnavailable
+	case game.ErrPlayerNotFound:
+		return http.StatusNotFound
+	case game.ErrNotRunning, game.ErrNotPaused:
+		return http.StatusConflict
+	}
+	return http.StatusBadRequest
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
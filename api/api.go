@@ -0,0 +1,248 @@
+// Package api serves a read-only JSON view of the game over HTTP, for clients that
+// don't hold a WebSocket: the current round, past rounds, leaderboards and players.
+package api
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/game"
+	"github.com/COAOX/zecrey_warrior/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	prefix = "/api/v1/"
+
+	defaultLimit = 20
+	maxLimit     = 100
+
+	// how long clients and proxies may reuse a response
+	liveMaxAge    = time.Second // the current round changes with every vote
+	rankMaxAge    = 10 * time.Second
+	historyMaxAge = 30 * time.Second
+	roundMaxAge   = time.Hour // a settled round never changes
+)
+
+var (
+	errInvalidParam = errors.New("invalid parameter")
+	errNotFound     = errors.New("not found")
+	errUnavailable  = errors.New("database unavailable, try again later")
+	errInternal     = errors.New("internal error")
+)
+
+type Response struct {
+	Code   int    `json:"code"`
+	Result string `json:"result"`
+}
+
+// Page is a slice of a list, from Offset. A page shorter than Limit is the last one.
+type Page struct {
+	Offset int         `json:"offset"`
+	Limit  int         `json:"limit"`
+	Items  interface{} `json:"items"`
+}
+
+// RoundDetail is a past round with the votes each camp got.
+type RoundDetail struct {
+	model.Game
+	Votes       map[game.Camp]int64 `json:"votes"`
+	WinnerVotes int64               `json:"winner_votes"`
+}
+
+type Server struct {
+	db   *db.Client
+	game *game.Game
+}
+
+func New(db *db.Client, game *game.Game) *Server {
+	return &Server{db: db, game: game}
+}
+
+// Routes registers the api on mux, under /api/v1/.
+func (s *Server) Routes(mux *http.ServeMux) {
+	mux.HandleFunc(prefix+"round", s.get(s.round))
+	mux.HandleFunc(prefix+"rounds", s.get(s.rounds))
+	mux.HandleFunc(prefix+"rounds/", s.get(s.roundDetail))
+	mux.HandleFunc(prefix+"leaderboard/players", s.get(s.playerRank))
+	mux.HandleFunc(prefix+"leaderboard/camps", s.get(s.campRank))
+	mux.HandleFunc(prefix+"camps", s.get(s.camps))
+	mux.HandleFunc(prefix+"players/", s.get(s.player))
+}
+
+// round returns the GameInfo of the running round.
+func (s *Server) round(r *http.Request) (interface{}, time.Duration, error) {
+	info, err := s.game.GetGameInfo()
+	return info, liveMaxAge, err
+}
+
+// rounds returns the past rounds that have a winner, newest first.
+func (s *Server) rounds(r *http.Request) (interface{}, time.Duration, error) {
+	offset, limit, err := pagination(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	games, err := s.db.Game.ListHistory(offset, limit)
+	return Page{Offset: offset, Limit: limit, Items: games}, historyMaxAge, err
+}
+
+// roundDetail returns the round /rounds/{id}.
+func (s *Server) roundDetail(r *http.Request) (interface{}, time.Duration, error) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, prefix+"rounds/"), 10, 0)
+	if err != nil {
+		return nil, 0, errInvalidParam
+	}
+	gm, err := s.db.Game.Get(uint(id))
+	if err != nil {
+		return nil, 0, err
+	}
+	votes, err := s.db.Player.CountVotes(gm.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	d := RoundDetail{Game: gm, Votes: make(map[game.Camp]int64, len(votes))}
+	for camp, n := range votes {
+		d.Votes[game.Camp(camp)] = n
+	}
+	d.WinnerVotes = votes[gm.WinnerID]
+	maxAge := historyMaxAge
+	if gm.Settled {
+		maxAge = roundMaxAge
+	} else if gm.ID == s.game.GetGameID() {
+		maxAge = liveMaxAge
+	}
+	return d, maxAge, nil
+}
+
+func (s *Server) playerRank(r *http.Request) (interface{}, time.Duration, error) {
+	offset, limit, err := pagination(r)
+	if err != nil {
+		return nil, 0, err
+	}
+	players, err := s.db.Player.ListRank(offset, limit)
+	return Page{Offset: offset, Limit: limit, Items: players}, rankMaxAge, err
+}
+
+func (s *Server) campRank(r *http.Request) (interface{}, time.Duration, error) {
+	camps, err := s.db.Camp.ListRank(len(model.Camps))
+	return camps, rankMaxAge, err
+}
+
+// camps returns the camps a player can vote for, in id order.
+func (s *Server) camps(r *http.Request) (interface{}, time.Duration, error) {
+	ranked, err := s.db.Camp.ListRank(len(model.Camps))
+	if err != nil {
+		return nil, 0, err
+	}
+	scores := map[uint8]int{}
+	for _, c := range ranked {
+		scores[c.ID] = c.Score
+	}
+	camps := make([]model.Camp, 0, len(model.Camps))
+	for _, c := range model.Camps {
+		c.Score = scores[c.ID]
+		camps = append(camps, c)
+	}
+	return camps, rankMaxAge, nil
+}
+
+// player returns the profile /players/{id}.
+func (s *Server) player(r *http.Request) (interface{}, time.Duration, error) {
+	id, err := strconv.ParseUint(strings.TrimPrefix(r.URL.Path, prefix+"players/"), 10, 64)
+	if err != nil {
+		return nil, 0, errInvalidParam
+	}
+	p, err := s.db.Player.Get(id)
+	return p, rankMaxAge, err
+}
+
+// pagination reads ?offset and ?limit, limit defaults to defaultLimit and is capped at maxLimit.
+func pagination(r *http.Request) (int, int, error) {
+	offset, limit := 0, defaultLimit
+	q := r.URL.Query()
+	if v := q.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errInvalidParam
+		}
+		offset = n
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return 0, 0, errInvalidParam
+		}
+		limit = n
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	return offset, limit, nil
+}
+
+// get serves f to GET and HEAD requests. Responses may be cached for the duration f
+// returns and carry an ETag, a request with a matching If-None-Match gets a 304.
+func (s *Server) get(f func(*http.Request) (interface{}, time.Duration, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Access-Control-Allow-Origin", "*")
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			writeJSON(w, http.StatusMethodNotAllowed, Response{Code: http.StatusMethodNotAllowed, Result: "method not allowed"})
+			return
+		}
+		v, maxAge, err := f(r)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		b, err := json.Marshal(v)
+		if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		sum := sha1.Sum(b)
+		etag := `"` + hex.EncodeToString(sum[:8]) + `"`
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(int(maxAge.Seconds())))
+		w.Header().Set("ETag", etag)
+		if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		if r.Method == http.MethodGet {
+			w.Write(b)
+		}
+	}
+}
+
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, errInvalidParam):
+		code = http.StatusBadRequest
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		code, err = http.StatusNotFound, errNotFound
+	case s.db.Degraded():
+		code, err = http.StatusServiceUnavailable, errUnavailable
+	default:
+		zap.L().Error("api request failed", zap.String("path", r.URL.Path), zap.Error(err))
+		err = errInternal
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, code, Response{Code: code, Result: err.Error()})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/game"
+	"github.com/COAOX/zecrey_warrior/model"
+)
+
+// newTestServer serves the api over the in-memory driver, with 30 settled rounds
+// won by BTC and player 1.
+func newTestServer(t *testing.T) (http.Handler, *db.Client) {
+	d := db.NewMemoryClient()
+	cfg := &config.Config{FPS: 30, GameDuration: 600, FrontendType: "test"}
+	g := game.NewGame(context.Background(), cfg, d, db.NewWriter(d, db.WriterConfig{}), func(context.Context) {}, func(context.Context) {}, func(camp game.Camp, votes int32) {})
+	if err := d.Player.Create(&model.Player{PlayerID: 1, Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 30; i++ {
+		gm := &model.Game{StartTime: time.Now().Add(-time.Minute), EndTime: time.Now()}
+		if err := d.Game.Create(gm); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Player.AddVote(&model.PlayerVote{GameID: gm.ID, PlayerID: 1, Camp: uint8(game.BTC)}); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Game.Settle(gm.ID, uint8(game.BTC), gm.EndTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mux := http.NewServeMux()
+	New(d, g).Routes(mux)
+	return mux, d
+}
+
+func serve(h http.Handler, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	return w
+}
+
+func TestPagination(t *testing.T) {
+	h, _ := newTestServer(t)
+	tests := []struct {
+		query         string
+		code          int
+		offset, limit int
+		items         int
+	}{
+		{"", http.StatusOK, 0, defaultLimit, defaultLimit},
+		{"?limit=5", http.StatusOK, 0, 5, 5},
+		{"?offset=25&limit=10", http.StatusOK, 25, 10, 5},
+		{"?offset=100", http.StatusOK, 100, defaultLimit, 0},
+		{"?limit=1000", http.StatusOK, 0, maxLimit, 30},
+		{"?limit=0", http.StatusBadRequest, 0, 0, 0},
+		{"?limit=-1", http.StatusBadRequest, 0, 0, 0},
+		{"?offset=-1", http.StatusBadRequest, 0, 0, 0},
+		{"?offset=one", http.StatusBadRequest, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		w := serve(h, http.MethodGet, prefix+"rounds"+tt.query, nil)
+		if w.Code != tt.code {
+			t.Errorf("rounds%s: %d, want %d", tt.query, w.Code, tt.code)
+			continue
+		}
+		if tt.code != http.StatusOK {
+			continue
+		}
+		var page struct {
+			Offset int          `json:"offset"`
+			Limit  int          `json:"limit"`
+			Items  []model.Game `json:"items"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+		if page.Offset != tt.offset || page.Limit != tt.limit || len(page.Items) != tt.items {
+			t.Errorf("rounds%s: offset %d limit %d, %d items, want %d %d %d", tt.query, page.Offset, page.Limit, len(page.Items), tt.offset, tt.limit, tt.items)
+		}
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	h, _ := newTestServer(t)
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"rounds/2", http.StatusOK},
+		{"rounds/999", http.StatusNotFound},
+		{"rounds/abc", http.StatusBadRequest},
+		{"rounds/-1", http.StatusBadRequest},
+		{"rounds/", http.StatusBadRequest},
+		{"players/1", http.StatusOK},
+		{"players/999", http.StatusNotFound},
+		{"players/abc", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := serve(h, http.MethodGet, prefix+tt.path, nil); w.Code != tt.code {
+			t.Errorf("%s: %d, want %d", tt.path, w.Code, tt.code)
+		}
+	}
+}
+
+func TestRoundDetail(t *testing.T) {
+	h, _ := newTestServer(t)
+	w := serve(h, http.MethodGet, prefix+"rounds/2", nil)
+	var d RoundDetail
+	if err := json.Unmarshal(w.Body.Bytes(), &d); err != nil {
+		t.Fatal(err)
+	}
+	if d.Winner.ShortName != "BTC" || d.WinnerVotes != 1 || d.Votes[game.BTC] != 1 {
+		t.Errorf("winner %q with %d votes, votes %v", d.Winner.ShortName, d.WinnerVotes, d.Votes)
+	}
+	if cc := w.Header().Get("Cache-Control"); cc != fmt.Sprintf("public, max-age=%d", int(roundMaxAge.Seconds())) {
+		t.Errorf("settled round cached as %q", cc)
+	}
+}
+
+func TestETag(t *testing.T) {
+	h, d := newTestServer(t)
+	path := prefix + "leaderboard/camps"
+	w := serve(h, http.MethodGet, path, nil)
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || etag == "" {
+		t.Fatalf("%d with ETag %q", w.Code, etag)
+	}
+
+	w = serve(h, http.MethodGet, path, http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("matching If-None-Match: %d with %d bytes, want 304 and no body", w.Code, w.Body.Len())
+	}
+	if w := serve(h, http.MethodGet, path, http.Header{"If-None-Match": {`"stale"`}}); w.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match: %d, want 200", w.Code)
+	}
+
+	// the tag follows the content
+	if err := d.Camp.IncreaseScore(uint8(game.ETH)); err != nil {
+		t.Fatal(err)
+	}
+	w = serve(h, http.MethodGet, path, http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("changed content: %d with ETag %q, want 200 and a new tag", w.Code, w.Header().Get("ETag"))
+	}
+}
+
+func TestMethods(t *testing.T) {
+	h, _ := newTestServer(t)
+	path := prefix + "camps"
+	get := serve(h, http.MethodGet, path, nil)
+
+	head := serve(h, http.MethodHead, path, nil)
+	if head.Code != http.StatusOK || head.Body.Len() != 0 {
+		t.Errorf("HEAD: %d with %d bytes, want 200 and no body", head.Code, head.Body.Len())
+	}
+	if head.Header().Get("ETag") != get.Header().Get("ETag") {
+		t.Errorf("HEAD ETag %q, GET %q", head.Header().Get("ETag"), get.Header().Get("ETag"))
+	}
+
+	for _, method := range []string{http.MethodPost, http.MethodPut, http.MethodDelete} {
+		w := serve(h, method, path, nil)
+		if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+			t.Errorf("%s: %d, Allow %q, want 405 and GET, HEAD", method, w.Code, w.Header().Get("Allow"))
+		}
+	}
+}
@@ -2,44 +2,90 @@ package chat
 
 import (
 	"context"
-	"fmt"
+	"errors"
 	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/COAOX/zecrey_warrior/config"
 	"github.com/COAOX/zecrey_warrior/db"
 	"github.com/COAOX/zecrey_warrior/game"
+	"github.com/COAOX/zecrey_warrior/ingest"
+	"github.com/COAOX/zecrey_warrior/logging"
+	"github.com/COAOX/zecrey_warrior/metrics"
 	"github.com/COAOX/zecrey_warrior/model"
-	"github.com/topfreegames/pitaya/constants"
+	"github.com/COAOX/zecrey_warrior/role"
 	"github.com/topfreegames/pitaya/v2"
 	"github.com/topfreegames/pitaya/v2/component"
+	"github.com/topfreegames/pitaya/v2/constants"
 	"go.uber.org/zap"
 )
 
+// maxDirectMessageLen is the longest direct message, in characters.
+const maxDirectMessageLen = 500
+
+var (
+	errNotJoined            = errors.New("player not joined")
+	errInvalidDirectMessage = errors.New("invalid direct message")
+)
+
 type Room struct {
 	component.Base
-	app pitaya.Pitaya
-	cfg *config.Config
-	db  *db.Client
+	ctx    context.Context
+	cancel context.CancelFunc
+	app    pitaya.Pitaya
+	cfg    *config.Config
+	db     *db.Client
+	writer *db.Writer
 
-	game *game.Game
+	game    *game.Game
+	members members
+	players playerCache
 }
 
-func RegistRoom(app pitaya.Pitaya, db *db.Client, cfg *config.Config, game *game.Game) {
+func RegistRoom(app pitaya.Pitaya, db *db.Client, writer *db.Writer, cfg *config.Config, game *game.Game) *Room {
 	err := app.GroupCreate(context.Background(), config.ChatRoomName)
 	if err != nil {
 		panic(err)
 	}
 
-	app.Register(&Room{
-		app:  app,
-		db:   db,
-		cfg:  cfg,
-		game: game,
-	},
+	r := &Room{
+		app:    app,
+		db:     db,
+		writer: writer,
+		cfg:    cfg,
+		game:   game,
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	app.Register(r,
 		component.WithName(config.ChatRoomName),
 		component.WithNameFunc(strings.ToLower),
 	)
+	return r
+}
+
+// log returns the logger of the chat room, with the id of the current round.
+func (r *Room) log() *zap.Logger {
+	l := zap.L().With(logging.Room(config.ChatRoomName))
+	if r.game != nil {
+		l = l.With(logging.GameID(r.game.GetGameID()))
+	}
+	return l
+}
+
+func (r *Room) AfterInit() {
+	for _, c := range r.cfg.Ingest {
+		src, err := ingest.New(c)
+		if err != nil {
+			panic(err)
+		}
+		go r.ingest(src)
+	}
+}
+
+func (r *Room) Shutdown() {
+	r.cancel()
 }
 
 // JoinResponse represents the result of joining room
@@ -47,6 +93,8 @@ type JoinResponse struct {
 	Code     int           `json:"code"`
 	Result   string        `json:"result"`
 	GameInfo game.GameInfo `json:"game_info"`
+	Degraded bool          `json:"degraded"`
+	Role     role.Role     `json:"role"`
 }
 
 type MessageResponse struct {
@@ -59,6 +107,18 @@ type NewUser struct {
 	Content string `json:"content"`
 }
 
+type ConversationRequest struct {
+	PlayerID uint64 `json:"player_id"`
+	Offset   int    `json:"offset"`
+	Limit    int    `json:"limit"`
+}
+
+type ConversationResponse struct {
+	Code     int                   `json:"code"`
+	Result   string                `json:"result"`
+	Messages []model.DirectMessage `json:"messages"`
+}
+
 // Join room
 func (r *Room) Join(ctx context.Context, player *model.Player) (*JoinResponse, error) {
 	s := r.app.GetSessionFromCtx(ctx)
@@ -78,16 +138,32 @@ func (r *Room) Join(ctx context.Context, player *model.Player) (*JoinResponse, e
 	// s.Push("onHistoryMessage", messages)
 
 	if err := r.db.Player.Create(player); err != nil {
-		zap.L().Error("create player failed", zap.Error(err))
-		return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "create player, db issue"})
+		r.log().Error("create player failed", logging.PlayerID(player.PlayerID), zap.Error(err))
+		// the state comes from db.Monitor, a handler doesn't ping the database itself
+		if !r.db.Degraded() {
+			return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "create player, db issue"})
+		}
+		// database is down, let the player in and create it once it is back
+		p := *player
+		r.writer.Do(func(c *db.Client) error { return c.Player.Create(&p) })
 	}
 
 	// new user join group
 	r.app.GroupAddMember(ctx, config.ChatRoomName, s.UID()) // add session to group
+	r.members.add(*player, s.UID())
+	r.players.put(r.gameID(), *player)
+	s.Set(playerIDKey, player.PlayerID)
+	// moderators and casters keep their role, they chat but don't vote
+	if role.Of(s) == role.Spectator {
+		if err := role.Set(s, role.Participant); err != nil {
+			return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "join, set role"})
+		}
+	}
 
 	// on session close, remove it from group
 	s.OnClose(func() {
 		r.app.GroupRemoveMember(ctx, config.ChatRoomName, s.UID())
+		r.members.remove(player.PlayerID, s.UID())
 	})
 
 	info, err := r.game.GetGameInfo()
@@ -95,43 +171,170 @@ func (r *Room) Join(ctx context.Context, player *model.Player) (*JoinResponse, e
 		return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "get game info", "error": err.Error()})
 	}
 
-	fmt.Println(info)
-
-	return &JoinResponse{Result: "success", GameInfo: info}, nil
+	return &JoinResponse{Result: "success", GameInfo: info, Degraded: info.Degraded, Role: role.Of(s)}, nil
 }
 
 // Message sync last message to all members
 func (r *Room) Message(ctx context.Context, msg *model.Message) (*MessageResponse, error) {
-	err := r.db.Message.Create(msg)
-	if err != nil {
-		zap.L().Error("save message failed", zap.Error(err))
+	s := r.app.GetSessionFromCtx(ctx)
+	current := role.Of(s)
+	if !current.Can(role.Chat) {
+		return nil, pitaya.Error(role.ErrForbidden, "RH-403", map[string]string{"failed": "message, role not allowed"})
+	}
+	// a player only speaks for itself, the client doesn't say who it is
+	if !s.HasKey(playerIDKey) {
+		return nil, pitaya.Error(errNotJoined, "RH-401", map[string]string{"failed": "message, join chat first"})
 	}
+	msg.PlayerID = s.Uint64(playerIDKey)
+	if err := r.deliver(ctx, msg, current.Can(role.Vote)); err != nil {
+		return nil, pitaya.Error(err, "RH-400", map[string]string{"failed": "get player, playerID not found"})
+	}
+	metrics.ChatMessages.WithLabelValues("chat").Inc()
+	return &MessageResponse{
+		Result: "success",
+	}, nil
+}
 
-	p, err := r.db.Player.Get(msg.PlayerID)
+// deliver saves and broadcasts msg, notifies mentioned players and, when msg names a camp
+// and vote is set, registers the player's vote. Both the chat handler and the external
+// ingestors go through it.
+func (r *Room) deliver(ctx context.Context, msg *model.Message, vote bool) error {
+	p, err := r.player(msg.PlayerID)
 	if err != nil {
-		zap.L().Error("get player failed", zap.Error(err))
-		return nil, pitaya.Error(err, "RH-400", map[string]string{"failed": "get player, playerID not found"})
+		r.log().Error("get player failed", logging.PlayerID(msg.PlayerID), zap.Error(err))
+		return err
 	}
 
+	msg.CreatedAt = time.Now()
+	r.writer.SaveMessage(*msg)
+
 	msg.Player = p
+	mentioned := r.resolveMentions(msg.Message)
+	for _, m := range mentioned {
+		msg.Mentions = append(msg.Mentions, m.player.PlayerID)
+	}
 	err = r.app.GroupBroadcast(ctx, r.cfg.FrontendType, config.ChatRoomName, "onMessage", msg)
 	if err != nil {
-		zap.L().Error("broadcast message failed", zap.Error(err))
-	}
-
-	if camp := game.DecideCamp(msg.Message); camp != game.Empty && r.game != nil {
-		if err := r.db.Player.AddVote(&model.PlayerVote{
-			GameID:   r.game.GetGameID(),
-			PlayerID: msg.PlayerID,
-			Camp:     uint8(camp),
-		}); err == nil {
-			r.app.GroupBroadcast(ctx, r.cfg.FrontendType, config.GameRoomName, "onPlayerJoin", p)
-			r.game.AddPlayer(msg.PlayerID, game.DecideCamp(msg.Message))
-		} else {
-			zap.L().Error("add player vote failed", zap.Error(err))
+		r.log().Error("broadcast message failed", logging.PlayerID(msg.PlayerID), zap.Error(err))
+	}
+	r.notifyMentions(msg, mentioned)
+
+	// a player votes once per round, AddPlayer ignores players already in the arena
+	if camp := game.DecideCamp(msg.Message); vote && camp != game.Empty && r.game != nil {
+		r.game.AddPlayer(msg.PlayerID, p.Name, camp, func() {
+			// runs on the game loop, the broadcast must not hold it up
+			r.writer.AddVote(r.game.GameRef(), model.PlayerVote{
+				PlayerID: msg.PlayerID,
+				Camp:     uint8(camp),
+			})
+			go r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.GameRoomName, "onPlayerJoin", p)
+		})
+	}
+	return nil
+}
+
+// player returns the player from the cache, or from the database on a miss.
+func (r *Room) player(playerID uint64) (model.Player, error) {
+	gameID := r.gameID()
+	if p, ok := r.players.get(gameID, playerID); ok {
+		return p, nil
+	}
+	p, err := r.db.Player.Get(playerID)
+	if err != nil {
+		return p, err
+	}
+	r.players.put(gameID, p)
+	return p, nil
+}
+
+// gameID returns the id of the current round, 0 without a game.
+func (r *Room) gameID() uint {
+	if r.game == nil {
+		return 0
+	}
+	return r.game.GetGameID()
+}
+
+// Direct sends a private message from the session's player to another player
+func (r *Room) Direct(ctx context.Context, req *model.DirectMessage) (*MessageResponse, error) {
+	s := r.app.GetSessionFromCtx(ctx)
+	if !s.HasKey(playerIDKey) {
+		return nil, pitaya.Error(errNotJoined, "RH-401", map[string]string{"failed": "direct message, join chat first"})
+	}
+	// only the recipient and the text come from the client
+	msg := model.DirectMessage{FromID: s.Uint64(playerIDKey), ToID: req.ToID, Message: req.Message}
+	if msg.ToID == 0 || msg.ToID == msg.FromID || strings.TrimSpace(msg.Message) == "" || utf8.RuneCountInString(msg.Message) > maxDirectMessageLen {
+		return nil, pitaya.Error(errInvalidDirectMessage, "RH-400", map[string]string{"failed": "direct message, invalid recipient, empty or too long message"})
+	}
+
+	to, err := r.player(msg.ToID)
+	if err != nil {
+		r.log().Error("get player failed", logging.PlayerID(msg.FromID), zap.Uint64("to_id", msg.ToID), zap.Error(err))
+		return nil, pitaya.Error(err, "RH-400", map[string]string{"failed": "get player, playerID not found"})
+	}
+	if err := r.db.DirectMessage.Create(&msg); err != nil {
+		r.log().Error("save direct message failed", logging.PlayerID(msg.FromID), zap.Uint64("to_id", msg.ToID), zap.Error(err))
+		if !r.db.Degraded() {
+			return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "save direct message, db issue"})
+		}
+		dm := msg
+		r.writer.Do(func(c *db.Client) error { return c.DirectMessage.Create(&dm) })
+	}
+	msg.To = to
+	if from, ok := r.members.get(msg.FromID); ok {
+		msg.From = from.player
+	}
+
+	if m, ok := r.members.get(msg.ToID); ok {
+		if _, err := r.app.SendPushToUsers("onDirectMessage", msg, []string{m.uid}, r.cfg.FrontendType); err != nil {
+			r.log().Error("push direct message failed", logging.PlayerID(msg.FromID), zap.Uint64("to_id", msg.ToID), zap.Error(err))
 		}
 	}
 	return &MessageResponse{
 		Result: "success",
 	}, nil
 }
+
+// Conversation returns the private messages between the session's player and another player
+func (r *Room) Conversation(ctx context.Context, req *ConversationRequest) (*ConversationResponse, error) {
+	s := r.app.GetSessionFromCtx(ctx)
+	if !s.HasKey(playerIDKey) {
+		return nil, pitaya.Error(errNotJoined, "RH-401", map[string]string{"failed": "conversation, join chat first"})
+	}
+	if req.Limit <= 0 || req.Limit > 100 {
+		req.Limit = 100
+	}
+	messages, err := r.db.DirectMessage.ListBetween(s.Uint64(playerIDKey), req.PlayerID, req.Offset, req.Limit)
+	if err != nil {
+		r.log().Error("list direct messages failed", logging.PlayerID(s.Uint64(playerIDKey)), zap.Error(err))
+		return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "list direct messages, db issue"})
+	}
+	return &ConversationResponse{Result: "success", Messages: messages}, nil
+}
+
+// Kick disconnects the player's chat session, it reports whether the player was online.
+func (r *Room) Kick(playerID uint64) bool {
+	m, ok := r.members.get(playerID)
+	if !ok {
+		return false
+	}
+	if _, err := r.app.SendKickToUsers([]string{m.uid}, r.cfg.FrontendType); err != nil {
+		r.log().Error("kick player failed", logging.PlayerID(playerID), zap.Error(err))
+	}
+	return true
+}
+
+func (r *Room) notifyMentions(msg *model.Message, mentioned []*member) {
+	uids := []string{}
+	for _, m := range mentioned {
+		if m.player.PlayerID != msg.PlayerID {
+			uids = append(uids, m.uid)
+		}
+	}
+	if len(uids) == 0 {
+		return
+	}
+	if _, err := r.app.SendPushToUsers("onMention", msg, uids, r.cfg.FrontendType); err != nil {
+		r.log().Error("push mention failed", logging.PlayerID(msg.PlayerID), zap.Error(err))
+	}
+}
@@ -0,0 +1,78 @@
+package chat
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/model"
+	"github.com/COAOX/zecrey_warrior/role"
+	"github.com/topfreegames/pitaya/v2"
+	pitayaerrors "github.com/topfreegames/pitaya/v2/errors"
+	"github.com/topfreegames/pitaya/v2/session"
+)
+
+// testApp serves the session of the handler and records the broadcasts, the
+// handlers under test don't call the rest of pitaya.Pitaya.
+type testApp struct {
+	pitaya.Pitaya
+	s      session.Session
+	routes []string
+}
+
+func (a *testApp) GetSessionFromCtx(context.Context) session.Session {
+	return a.s
+}
+
+func (a *testApp) GroupBroadcast(_ context.Context, _, _, route string, _ interface{}) error {
+	a.routes = append(a.routes, route)
+	return nil
+}
+
+func TestMessageRoles(t *testing.T) {
+	d := db.NewMemoryClient()
+	if err := d.Player.Create(&model.Player{PlayerID: 1, Name: "alice"}); err != nil {
+		t.Fatal(err)
+	}
+	pool := session.NewSessionPool()
+	tests := []struct {
+		role   role.Role
+		joined bool   // the session joined the chat as player 1
+		code   string // of the error, empty if the message goes through
+	}{
+		{role.Spectator, true, "RH-403"},
+		{role.Participant, true, ""},
+		{role.Moderator, true, ""},
+		{role.Caster, true, ""},
+		{role.Participant, false, "RH-401"},
+		{role.Moderator, false, "RH-401"},
+	}
+	for _, tt := range tests {
+		s := pool.NewSession(nil, true)
+		if tt.joined {
+			s.Set(playerIDKey, uint64(1))
+		}
+		if tt.role != role.Spectator {
+			if err := role.Set(s, tt.role); err != nil {
+				t.Fatal(err)
+			}
+		}
+		app := &testApp{s: s}
+		r := &Room{ctx: context.Background(), app: app, cfg: &config.Config{}, db: d, writer: db.NewWriter(d, db.WriterConfig{})}
+
+		// the client can't speak for another player, 2 isn't even known
+		_, err := r.Message(context.Background(), &model.Message{PlayerID: 2, Message: "go btc"})
+		var perr *pitayaerrors.Error
+		switch {
+		case tt.code == "" && err != nil:
+			t.Errorf("%s joined %v: message failed: %v", tt.role, tt.joined, err)
+		case tt.code != "" && (!errors.As(err, &perr) || perr.Code != tt.code):
+			t.Errorf("%s joined %v: got %v, want %s", tt.role, tt.joined, err, tt.code)
+		}
+		if sent := len(app.routes) > 0; sent != (tt.code == "") {
+			t.Errorf("%s joined %v: broadcasts %v", tt.role, tt.joined, app.routes)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package chat
+
+import (
+	"github.com/COAOX/zecrey_warrior/ingest"
+	"github.com/COAOX/zecrey_warrior/logging"
+	"github.com/COAOX/zecrey_warrior/metrics"
+	"github.com/COAOX/zecrey_warrior/model"
+	"go.uber.org/zap"
+)
+
+const ingestBuffer = 256
+
+// ingest feeds the messages of an external chat source into the chat and vote pipeline,
+// registering every external user as a player on first sight.
+func (r *Room) ingest(src ingest.Source) {
+	msgs := make(chan ingest.Message, ingestBuffer)
+	go func() {
+		defer close(msgs)
+		if err := src.Run(r.ctx, msgs); err != nil && r.ctx.Err() == nil {
+			r.log().Error("ingest source stopped", zap.String("source", src.Name()), zap.Error(err))
+		}
+	}()
+
+	known := map[uint64]bool{}
+	for m := range msgs {
+		playerID := ingest.PlayerID(m.Source, m.Username)
+		if !known[playerID] {
+			// Create upserts every column, don't reset the score of a returning user
+			if _, err := r.player(playerID); err != nil {
+				if err := r.db.Player.Create(&model.Player{PlayerID: playerID, Name: m.Username}); err != nil {
+					r.log().Error("create ingested player failed", logging.PlayerID(playerID), zap.String("source", m.Source), zap.String("username", m.Username), zap.Error(err))
+					continue
+				}
+			}
+			known[playerID] = true
+		}
+		msg := &model.Message{PlayerID: playerID, Message: m.Text}
+		if err := r.deliver(r.ctx, msg, true); err != nil {
+			r.log().Error("deliver ingested message failed", logging.PlayerID(playerID), zap.String("source", m.Source), zap.Error(err))
+			continue
+		}
+		metrics.ChatMessages.WithLabelValues(m.Source).Inc()
+	}
+}
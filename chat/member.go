@@ -0,0 +1,83 @@
+package chat
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/model"
+)
+
+const playerIDKey = config.PlayerIDKey
+
+type member struct {
+	player model.Player
+	uid    string
+}
+
+// members tracks the players currently connected to the chat room, so that
+// mentions and direct messages can be pushed to their sessions.
+type members struct {
+	byID sync.Map // player id -> *member
+}
+
+func (m *members) add(player model.Player, uid string) {
+	m.byID.Store(player.PlayerID, &member{player: player, uid: uid})
+}
+
+func (m *members) remove(playerID uint64, uid string) {
+	if v, ok := m.byID.Load(playerID); ok && v.(*member).uid == uid {
+		m.byID.Delete(playerID)
+	}
+}
+
+func (m *members) get(playerID uint64) (*member, bool) {
+	v, ok := m.byID.Load(playerID)
+	if !ok {
+		return nil, false
+	}
+	return v.(*member), true
+}
+
+// findByName looks up an online player by name, ignoring case.
+func (m *members) findByName(name string) (*member, bool) {
+	var found *member
+	m.byID.Range(func(key, value interface{}) bool {
+		if v := value.(*member); strings.EqualFold(v.player.Name, name) {
+			found = v
+			return false
+		}
+		return true
+	})
+	return found, found != nil
+}
+
+// playerCache keeps the players seen during the current round, it saves a query
+// per message. It is emptied when the round changes, settling a round changes scores.
+type playerCache struct {
+	mu      sync.Mutex
+	gameID  uint
+	players map[uint64]model.Player
+}
+
+func (c *playerCache) get(gameID uint, playerID uint64) (model.Player, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset(gameID)
+	p, ok := c.players[playerID]
+	return p, ok
+}
+
+func (c *playerCache) put(gameID uint, p model.Player) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.reset(gameID)
+	c.players[p.PlayerID] = p
+}
+
+// reset empties the cache if it holds the players of another round than gameID.
+func (c *playerCache) reset(gameID uint) {
+	if c.players == nil || c.gameID != gameID {
+		c.gameID, c.players = gameID, map[uint64]model.Player{}
+	}
+}
@@ -0,0 +1,26 @@
+package chat
+
+import (
+	"testing"
+
+	"github.com/COAOX/zecrey_warrior/model"
+)
+
+func TestPlayerCache(t *testing.T) {
+	var c playerCache
+	if _, ok := c.get(1, 7); ok {
+		t.Fatal("hit on an empty cache")
+	}
+	c.put(1, model.Player{PlayerID: 7, Name: "alice", Score: 3})
+	if p, ok := c.get(1, 7); !ok || p.Score != 3 {
+		t.Fatalf("got %+v %v, want the cached player", p, ok)
+	}
+	// the next round starts with fresh scores
+	if _, ok := c.get(2, 7); ok {
+		t.Error("a player of the previous round was returned")
+	}
+	c.put(2, model.Player{PlayerID: 8})
+	if len(c.players) != 1 {
+		t.Errorf("%d players cached, want only the round's", len(c.players))
+	}
+}
@@ -0,0 +1,43 @@
+package chat
+
+import (
+	"regexp"
+	"strconv"
+)
+
+var mentionRegexp = regexp.MustCompile(`@([\p{L}\p{N}_.-]+)`)
+
+// parseMentions returns the names or player ids mentioned with "@" in msg,
+// in order of appearance and without duplicates.
+func parseMentions(msg string) []string {
+	matches := mentionRegexp.FindAllStringSubmatch(msg, -1)
+	seen := make(map[string]bool, len(matches))
+	mentions := []string{}
+	for _, m := range matches {
+		if !seen[m[1]] {
+			seen[m[1]] = true
+			mentions = append(mentions, m[1])
+		}
+	}
+	return mentions
+}
+
+// resolveMentions maps the mentions in msg to the online members they refer to.
+// A mention matches a player name first, then a numeric player id.
+func (r *Room) resolveMentions(msg string) []*member {
+	resolved := []*member{}
+	seen := map[uint64]bool{}
+	for _, mention := range parseMentions(msg) {
+		m, ok := r.members.findByName(mention)
+		if !ok {
+			if id, err := strconv.ParseUint(mention, 10, 64); err == nil {
+				m, ok = r.members.get(id)
+			}
+		}
+		if ok && !seen[m.player.PlayerID] {
+			seen[m.player.PlayerID] = true
+			resolved = append(resolved, m)
+		}
+	}
+	return resolved
+}
@@ -0,0 +1,60 @@
+package chat
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/COAOX/zecrey_warrior/model"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want []string
+	}{
+		{"", []string{}},
+		{"no mention here", []string{}},
+		{"@alice hi", []string{"alice"}},
+		{"hi @alice and @bob_2!", []string{"alice", "bob_2"}},
+		{"@alice @alice @Alice", []string{"alice", "Alice"}},
+		{"@12345 go btc", []string{"12345"}},
+		{"@ alone", []string{}},
+		{"@élodie", []string{"élodie"}},
+	}
+	for _, tt := range tests {
+		if got := parseMentions(tt.msg); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseMentions(%q) = %q, want %q", tt.msg, got, tt.want)
+		}
+	}
+}
+
+func TestResolveMentions(t *testing.T) {
+	r := &Room{}
+	r.members.add(model.Player{PlayerID: 1, Name: "alice"}, "u1")
+	r.members.add(model.Player{PlayerID: 2, Name: "bob"}, "u2")
+	r.members.add(model.Player{PlayerID: 3, Name: "2"}, "u3")
+
+	tests := []struct {
+		msg  string
+		want []uint64
+	}{
+		{"hello", nil},
+		{"@ALICE", []uint64{1}},
+		{"@bob @alice", []uint64{2, 1}},
+		// a name wins over a player id
+		{"@2", []uint64{3}},
+		{"@1", []uint64{1}},
+		// the same player mentioned by name and by id
+		{"@alice @1", []uint64{1}},
+		{"@carol @99", nil},
+	}
+	for _, tt := range tests {
+		var got []uint64
+		for _, m := range r.resolveMentions(tt.msg) {
+			got = append(got, m.player.PlayerID)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("resolveMentions(%q) = %v, want %v", tt.msg, got, tt.want)
+		}
+	}
+}
@@ -2,33 +2,162 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"os"
 
 	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/ingest"
 )
 
 const (
-	ChatRoomName = "chat"
-	GameRoomName = "game"
+	ChatRoomName  = "chat"
+	GameRoomName  = "game"
+	AdminRoomName = "admin"
+	// CasterGroupName is the group of the sessions getting enriched frames.
+	CasterGroupName = "caster"
+
+	// PlayerIDKey is the session key chat.join stores the player id under.
+	PlayerIDKey = "player_id"
+
+	maxFPS = 1000
+
+	// DefaultTickRate is the simulation rate when tick_rate is not set.
+	DefaultTickRate = 60
+
+	BallCollisionOff    = "off"
+	BallCollisionBounce = "bounce"
+	BallCollisionSteal  = "steal"
+
+	LogFormatJSON    = "json"
+	LogFormatConsole = "console"
+
+	// AdminTokenEnv overrides admin_token, the token is better kept out of the config file.
+	AdminTokenEnv = "ZECREY_ADMIN_TOKEN"
 )
 
 type Config struct {
-	Database          db.Config `json:"database"`
-	FPS               int       `json:"fps"`
-	GameRoundInterval int       `json:"game_round_interval"`
-	FrontendType      string    `json:"frontend_type"`
-	ItemFrameChance   int       `json:"item_frame_chance"`
-	GameDuration      int       `json:"game_duration"`
+	Database db.Config `json:"database"`
+	// FPS is how many frames per second are sent to clients, TickRate how many
+	// times per second the game is simulated, which needs a restart to change.
+	FPS               int    `json:"fps"`
+	TickRate          int    `json:"tick_rate"`
+	GameRoundInterval int    `json:"game_round_interval"`
+	FrontendType      string `json:"frontend_type"`
+	ItemFrameChance   int    `json:"item_frame_chance"`
+	GameDuration      int    `json:"game_duration"`
+	CheersPerItem     int    `json:"cheers_per_item"`
+	AdminToken        string `json:"admin_token"`  // empty disables the moderator role, see AdminTokenEnv
+	CasterToken       string `json:"caster_token"` // empty disables the caster role
+	ReloadInterval    int    `json:"reload_interval"`
+
+	// CheckpointInterval is how often, in seconds, the running round is checkpointed, 0 disables it.
+	CheckpointInterval int `json:"checkpoint_interval"`
+
+	Shutdown ShutdownConfig `json:"shutdown"`
+
+	BallCollisions BallCollisionConfig `json:"ball_collisions"`
+
+	Log LogConfig `json:"log"`
+
+	Ingest []ingest.Config `json:"ingest"`
+}
+
+// ShutdownConfig tunes what happens on SIGINT and SIGTERM, zero values use the defaults of main.
+type ShutdownConfig struct {
+	Timeout   int  `json:"timeout"`    // seconds before clients are disconnected, whatever happens
+	Notice    int  `json:"notice"`     // seconds between the maintenance notice and disconnecting clients
+	WaitRound bool `json:"wait_round"` // wait for the round to end instead of checkpointing it, if it ends in time
+}
+
+// BallCollisionConfig sets how balls collide with each other, a reloaded one applies from the next round.
+type BallCollisionConfig struct {
+	Mode         string `json:"mode"`          // off, bounce or steal, the faster ball taking speed from the slower one; empty is off
+	OpposingOnly bool   `json:"opposing_only"` // balls of the same camp pass through each other
+}
+
+// LogConfig sets up the logger of the server, pitaya's included. It needs a restart to change.
+type LogConfig struct {
+	Level  string `json:"level"`  // debug, info, warn or error; empty is info
+	Format string `json:"format"` // json or console; empty is json
 }
 
 func Read(configPath string) *Config {
-	b, err := os.ReadFile(configPath)
+	config, err := Load(configPath)
 	if err != nil {
 		panic(err)
 	}
+	return config
+}
+
+// Load reads and validates the config file.
+func Load(configPath string) (*Config, error) {
+	b, err := os.ReadFile(configPath)
+	if err != nil {
+		return nil, err
+	}
 	var config Config
 	if err := json.Unmarshal(b, &config); err != nil {
-		panic(err)
+		return nil, err
+	}
+	if token := os.Getenv(AdminTokenEnv); token != "" {
+		config.AdminToken = token
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", configPath, err)
 	}
-	return &config
+	return &config, nil
+}
+
+func (c *Config) Validate() error {
+	switch {
+	case c.FPS <= 0 || c.FPS > maxFPS:
+		return fmt.Errorf("fps must be in [1, %d], got %d", maxFPS, c.FPS)
+	case c.TickRate < 0 || c.TickRate > maxFPS:
+		return fmt.Errorf("tick_rate must be in [0, %d], got %d", maxFPS, c.TickRate)
+	case c.ItemFrameChance <= 0:
+		return fmt.Errorf("item_frame_chance must be positive, got %d", c.ItemFrameChance)
+	case c.GameDuration <= 0:
+		return fmt.Errorf("game_duration must be positive, got %d", c.GameDuration)
+	case c.GameRoundInterval < 0:
+		return fmt.Errorf("game_round_interval must not be negative, got %d", c.GameRoundInterval)
+	case c.CheersPerItem < 0:
+		return fmt.Errorf("cheers_per_item must not be negative, got %d", c.CheersPerItem)
+	case c.ReloadInterval < 0:
+		return fmt.Errorf("reload_interval must not be negative, got %d", c.ReloadInterval)
+	case c.CheckpointInterval < 0:
+		return fmt.Errorf("checkpoint_interval must not be negative, got %d", c.CheckpointInterval)
+	case c.Shutdown.Timeout < 0 || c.Shutdown.Notice < 0:
+		return fmt.Errorf("shutdown timeout and notice must not be negative")
+	case c.BallCollisions.Mode != "" && c.BallCollisions.Mode != BallCollisionOff &&
+		c.BallCollisions.Mode != BallCollisionBounce && c.BallCollisions.Mode != BallCollisionSteal:
+		return fmt.Errorf("ball_collisions mode must be %s, %s or %s, got %q", BallCollisionOff, BallCollisionBounce, BallCollisionSteal, c.BallCollisions.Mode)
+	case c.Log.Level != "" && c.Log.Level != "debug" && c.Log.Level != "info" && c.Log.Level != "warn" && c.Log.Level != "error":
+		return fmt.Errorf("log level must be debug, info, warn or error, got %q", c.Log.Level)
+	case c.Log.Format != "" && c.Log.Format != LogFormatJSON && c.Log.Format != LogFormatConsole:
+		return fmt.Errorf("log format must be %s or %s, got %q", LogFormatJSON, LogFormatConsole, c.Log.Format)
+	case c.FrontendType == "":
+		return fmt.Errorf("frontend_type is required")
+	}
+	return nil
+}
+
+// SimulationRate returns TickRate, or DefaultTickRate if it is not set.
+func (c *Config) SimulationRate() int {
+	if c.TickRate == 0 {
+		return DefaultTickRate
+	}
+	return c.TickRate
+}
+
+// WithSafe returns a copy of c with the settings of next that can change while the
+// server runs, c is left untouched. Database, frontend, ingest and admin settings need a restart.
+func (c *Config) WithSafe(next *Config) *Config {
+	v := *c
+	v.FPS = next.FPS
+	v.GameRoundInterval = next.GameRoundInterval
+	v.ItemFrameChance = next.ItemFrameChance
+	v.GameDuration = next.GameDuration
+	v.CheersPerItem = next.CheersPerItem
+	v.BallCollisions = next.BallCollisions
+	return &v
 }
@@ -0,0 +1,67 @@
+package config
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Watcher reloads the config file when it changes, or on demand, and hands
+// valid configs to onChange. Invalid files are logged and ignored.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	onChange func(*Config)
+
+	modTime time.Time
+	size    int64
+}
+
+func NewWatcher(path string, interval time.Duration, onChange func(*Config)) *Watcher {
+	w := &Watcher{path: path, interval: interval, onChange: onChange}
+	if fi, err := os.Stat(path); err == nil {
+		w.modTime, w.size = fi.ModTime(), fi.Size()
+	}
+	return w
+}
+
+// Reload loads the config file and applies it if valid.
+func (w *Watcher) Reload() error {
+	c, err := Load(w.path)
+	if err != nil {
+		return err
+	}
+	zap.L().Info("config reloaded", zap.String("path", w.path))
+	w.onChange(c)
+	return nil
+}
+
+// Run polls the config file until ctx is done, a zero interval disables polling.
+func (w *Watcher) Run(ctx context.Context) {
+	if w.interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fi, err := os.Stat(w.path)
+			if err != nil {
+				zap.L().Error("stat config failed", zap.String("path", w.path), zap.Error(err))
+				continue
+			}
+			if fi.ModTime().Equal(w.modTime) && fi.Size() == w.size {
+				continue
+			}
+			w.modTime, w.size = fi.ModTime(), fi.Size()
+			if err := w.Reload(); err != nil {
+				zap.L().Error("reload config failed", zap.String("path", w.path), zap.Error(err))
+			}
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package db
+
+import (
+	"github.com/COAOX/zecrey_warrior/model"
+	"gorm.io/gorm/clause"
+)
+
+type checkpoint db
+
+// Save replaces the checkpoint of the round.
+func (c *checkpoint) Save(checkpoint *model.Checkpoint) error {
+	return c.db.Omit(clause.Associations).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "game_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"frame", "remaining", "state", "updated_at"}),
+	}).Create(checkpoint).Error
+}
+
+// LatestUnfinished returns the most recent checkpoint of a round that has neither ended nor been settled.
+func (c *checkpoint) LatestUnfinished() (model.Checkpoint, error) {
+	var checkpoint model.Checkpoint
+	err := c.db.Joins("JOIN games ON games.id = checkpoints.game_id AND games.deleted_at IS NULL").
+		Where("games.settled = ? AND games.winner_id = 0 AND checkpoints.remaining > 0", false).
+		Order("checkpoints.updated_at DESC").First(&checkpoint).Error
+	return checkpoint, err
+}
+
+func (c *checkpoint) Delete(gameID uint) error {
+	return c.db.Delete(&model.Checkpoint{}, "game_id = ?", gameID).Error
+}
@@ -3,26 +3,37 @@ package db
 import (
 	"fmt"
 
-	"github.com/COAOX/zecrey_warrior/model"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverMemory   = "memory"
 )
 
 type Config struct {
+	Driver   string `json:"driver"`
 	Host     string `json:"host"`
 	Port     int    `json:"port"`
 	User     string `json:"user"`
 	Password string `json:"password"`
 	Database string `json:"database"`
+
+	Writer WriterConfig `json:"writer"`
 }
 
 type Client struct {
-	*gorm.DB
-	Game    game
-	Camp    camp
-	Player  player
-	Message message
+	Game    GameStore
+	Camp    CampStore
+	Player  PlayerStore
+	Message MessageStore
+
+	DirectMessage DirectMessageStore
+	Checkpoint    CheckpointStore
+
+	gdb    *gorm.DB // nil for the in-memory driver
+	health health
 }
 
 type db struct {
@@ -30,27 +41,23 @@ type db struct {
 }
 
 func NewClient(cfg Config) *Client {
+	switch cfg.Driver {
+	case DriverMemory:
+		return NewMemoryClient()
+	case "", DriverPostgres:
+	default:
+		panic(fmt.Sprintf("unknown database driver %q", cfg.Driver))
+	}
+
 	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable", cfg.Host, cfg.User, cfg.Password, cfg.Database, cfg.Port)
+	// don't ping on open, an unreachable database degrades the client instead of failing the boot
 	gdb, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
 		DisableForeignKeyConstraintWhenMigrating: true,
+		DisableAutomaticPing:                     true,
 	})
 	if err != nil {
 		panic(err)
 	}
 
-	err = gdb.AutoMigrate(&model.Message{}, &model.Game{}, &model.Player{}, &model.Camp{}, &model.PlayerVote{})
-	if err != nil {
-		panic(err)
-	}
-
-	err = gdb.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "name"}},
-		UpdateAll: true,
-	}).Create(&model.Camps).Error
-	if err != nil {
-		panic(err)
-	}
-
-	return &Client{DB: gdb, Game: game{db: gdb}, Camp: camp{db: gdb}, Player: player{db: gdb}, Message: message{db: gdb}}
-	// return &Client{}
+	return &Client{gdb: gdb, Game: &game{db: gdb}, Camp: &camp{db: gdb}, Player: &player{db: gdb}, Message: &message{db: gdb}, DirectMessage: &directMessage{db: gdb}, Checkpoint: &checkpoint{db: gdb}}
 }
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"github.com/COAOX/zecrey_warrior/model"
+	"gorm.io/gorm/clause"
+)
+
+type directMessage db
+
+func (m *directMessage) Create(message *model.DirectMessage) error {
+	return m.db.Create(message).Error
+}
+
+// ListBetween returns the latest messages exchanged between two players, newest first.
+func (m *directMessage) ListBetween(a, b uint64, offset, size int) ([]model.DirectMessage, error) {
+	var messages []model.DirectMessage
+	err := m.db.Preload(clause.Associations).
+		Where("(from_id = ? AND to_id = ?) OR (from_id = ? AND to_id = ?)", a, b, b, a).
+		Order(clause.OrderByColumn{Column: clause.Column{Name: "created_at"}, Desc: true}).
+		Offset(offset).Limit(size).Find(&messages).Error
+	return messages, err
+}
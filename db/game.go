@@ -1,15 +1,70 @@
 package db
 
 import (
+	"errors"
+	"time"
+
 	"github.com/COAOX/zecrey_warrior/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+var ErrAlreadySettled = errors.New("game already settled")
+
 type game db
 
 func (g *game) Create(game *model.Game) error {
 	return g.db.Create(game).Error
 }
 
+// Get returns the round gameID with its winner camp.
+func (g *game) Get(gameID uint) (model.Game, error) {
+	var game model.Game
+	err := g.db.Preload("Winner").First(&game, gameID).Error
+	return game, err
+}
+
 func (g *game) Update(game *model.Game) error {
 	return g.db.Updates(game).Error
 }
+
+// Settle records the winner of a round and credits the winning camp and its voters,
+// all in one transaction. A round is settled at most once, later calls return ErrAlreadySettled.
+func (g *game) Settle(gameID uint, winnerID uint8, endTime time.Time) error {
+	return g.db.Transaction(func(tx *gorm.DB) error {
+		var game model.Game
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&game, gameID).Error; err != nil {
+			return err
+		}
+		if game.Settled {
+			return ErrAlreadySettled
+		}
+		err := tx.Model(&model.Game{}).Where("id = ?", gameID).Updates(map[string]interface{}{
+			"winner_id":  winnerID,
+			"end_time":   endTime,
+			"settled":    true,
+			"settled_at": time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := (&camp{db: tx}).IncreaseScore(winnerID); err != nil {
+			return err
+		}
+		return (&player{db: tx}).IncreaseScore(gameID, winnerID)
+	})
+}
+
+// ListUnsettled returns the rounds that ended before the given time with a winner but were not settled.
+func (g *game) ListUnsettled(before time.Time, limit int) ([]model.Game, error) {
+	var games []model.Game
+	err := g.db.Where("settled = ? AND winner_id <> 0 AND end_time < ?", false, before).Order("id").Limit(limit).Find(&games).Error
+	return games, err
+}
+
+// ListHistory returns the rounds that ended with a winner, newest first, with their winner camp.
+func (g *game) ListHistory(offset, size int) ([]model.Game, error) {
+	var games []model.Game
+	err := g.db.Preload("Winner").Where("winner_id <> 0").Order("id desc").Offset(offset).Limit(size).Find(&games).Error
+	return games, err
+}
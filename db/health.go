@@ -0,0 +1,134 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+var errForcedDegraded = errors.New("database marked unavailable")
+
+const (
+	healthCheckInterval = 2 * time.Second
+	pingTimeout         = time.Second
+)
+
+// health tracks whether the database answers. While it doesn't, the client is
+// degraded: the game keeps running on in-memory state and the Writer keeps the
+// writes for replay.
+type health struct {
+	degraded int32
+	prepared int32
+	mu       sync.Mutex // serializes checks, guards onChange
+	onChange []func(degraded bool)
+}
+
+func (c *Client) Ping(ctx context.Context) error {
+	if c.gdb == nil {
+		return nil
+	}
+	sqlDB, err := c.gdb.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// Degraded reports whether the database was unreachable at the last check.
+func (c *Client) Degraded() bool {
+	return atomic.LoadInt32(&c.health.degraded) == 1
+}
+
+// Prepare checks that the schema is up to date, see CheckSchema. When it fails,
+// the client is degraded and Monitor checks again once the database is back.
+func (c *Client) Prepare() error {
+	err := c.prepare()
+	if err != nil {
+		atomic.StoreInt32(&c.health.degraded, 1)
+	}
+	return err
+}
+
+func (c *Client) prepare() error {
+	err := c.CheckSchema()
+	if err == nil {
+		atomic.StoreInt32(&c.health.prepared, 1)
+	}
+	return err
+}
+
+// OnDegradedChange registers f to be called when the database becomes unavailable
+// or available again. f is called from Check, it must not call Check itself.
+func (c *Client) OnDegradedChange(f func(degraded bool)) {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	c.health.onChange = append(c.health.onChange, f)
+}
+
+// Check pings the database and updates the degraded state, it reports whether the state changed.
+func (c *Client) Check() bool {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	err := c.Ping(ctx)
+	if err == nil && atomic.LoadInt32(&c.health.prepared) == 0 {
+		err = c.prepare()
+	}
+
+	return c.setDegraded(err)
+}
+
+// SetDegraded marks the database unavailable, or available again, until the next check.
+// The in-memory driver never fails its checks, the tests use it to run degraded.
+func (c *Client) SetDegraded(degraded bool) bool {
+	c.health.mu.Lock()
+	defer c.health.mu.Unlock()
+	var err error
+	if degraded {
+		err = errForcedDegraded
+	}
+	return c.setDegraded(err)
+}
+
+// setDegraded must be called with health.mu held.
+func (c *Client) setDegraded(err error) bool {
+	degraded := int32(0)
+	if err != nil {
+		degraded = 1
+	}
+	if atomic.SwapInt32(&c.health.degraded, degraded) == degraded {
+		return false
+	}
+	if err != nil {
+		zap.L().Error("database unavailable, running degraded", zap.Error(err))
+	} else {
+		zap.L().Info("database available again")
+	}
+	for _, f := range c.health.onChange {
+		f(err != nil)
+	}
+	return true
+}
+
+// Monitor checks the database until ctx is done.
+func (c *Client) Monitor(ctx context.Context) {
+	if c.gdb == nil {
+		return
+	}
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.Check()
+		}
+	}
+}
@@ -0,0 +1,412 @@
+package db
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/model"
+	"gorm.io/gorm"
+)
+
+var ErrDuplicateVote = errors.New("player already voted in this game")
+
+// memory is a process local store, it lets the server and the tests run without Postgres.
+// Everything is lost on restart.
+type memory struct {
+	mu sync.RWMutex
+
+	gameSeq    uint
+	messageSeq uint
+
+	games          map[uint]model.Game
+	camps          map[uint8]model.Camp
+	players        map[uint64]model.Player
+	votes          map[uint]map[uint64]model.PlayerVote // game id -> player id -> vote
+	messages       []model.Message
+	directMessages []model.DirectMessage
+	checkpoints    map[uint]model.Checkpoint
+}
+
+type (
+	memoryGame          struct{ *memory }
+	memoryCamp          struct{ *memory }
+	memoryPlayer        struct{ *memory }
+	memoryMessage       struct{ *memory }
+	memoryDirectMessage struct{ *memory }
+	memoryCheckpoint    struct{ *memory }
+)
+
+func NewMemoryClient() *Client {
+	m := &memory{
+		games:   map[uint]model.Game{},
+		camps:   map[uint8]model.Camp{},
+		players: map[uint64]model.Player{},
+		votes:   map[uint]map[uint64]model.PlayerVote{},
+
+		checkpoints: map[uint]model.Checkpoint{},
+	}
+	for _, c := range model.Camps {
+		m.camps[c.ID] = c
+	}
+	return &Client{
+		Game:          memoryGame{m},
+		Camp:          memoryCamp{m},
+		Player:        memoryPlayer{m},
+		Message:       memoryMessage{m},
+		DirectMessage: memoryDirectMessage{m},
+		Checkpoint:    memoryCheckpoint{m},
+	}
+}
+
+func (g memoryGame) Create(game *model.Game) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.gameSeq++
+	game.ID = g.gameSeq
+	game.CreatedAt, game.UpdatedAt = time.Now(), time.Now()
+	g.games[game.ID] = *game
+	return nil
+}
+
+func (g memoryGame) Get(gameID uint) (model.Game, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	game, ok := g.games[gameID]
+	if !ok {
+		return model.Game{}, gorm.ErrRecordNotFound
+	}
+	game.Winner = g.camps[game.WinnerID]
+	return game, nil
+}
+
+func (g memoryGame) Update(game *model.Game) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	old, ok := g.games[game.ID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	game.UpdatedAt = time.Now()
+	updated := *game
+	if !updated.Settled {
+		// like gorm Updates, zero values don't overwrite
+		updated.Settled, updated.SettledAt = old.Settled, old.SettledAt
+	}
+	g.games[game.ID] = updated
+	return nil
+}
+
+func (g memoryGame) Settle(gameID uint, winnerID uint8, endTime time.Time) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	game, ok := g.games[gameID]
+	if !ok {
+		return gorm.ErrRecordNotFound
+	}
+	if game.Settled {
+		return ErrAlreadySettled
+	}
+	now := time.Now()
+	game.WinnerID, game.EndTime, game.Settled, game.SettledAt = winnerID, endTime, true, &now
+	g.games[gameID] = game
+
+	if camp, ok := g.camps[winnerID]; ok {
+		camp.Score++
+		g.camps[winnerID] = camp
+	}
+	for id, vote := range g.votes[gameID] {
+		if player, ok := g.players[id]; ok && vote.Camp == winnerID {
+			player.Score++
+			g.players[id] = player
+		}
+	}
+	return nil
+}
+
+func (g memoryGame) ListUnsettled(before time.Time, limit int) ([]model.Game, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	games := []model.Game{}
+	for _, game := range g.games {
+		if !game.Settled && game.WinnerID != 0 && game.EndTime.Before(before) {
+			games = append(games, game)
+		}
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].ID < games[j].ID })
+	return truncate(games, limit), nil
+}
+
+func (g memoryGame) ListHistory(offset, size int) ([]model.Game, error) {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	games := []model.Game{}
+	for _, game := range g.games {
+		if game.WinnerID != 0 {
+			game.Winner = g.camps[game.WinnerID]
+			games = append(games, game)
+		}
+	}
+	sort.Slice(games, func(i, j int) bool { return games[i].ID > games[j].ID })
+	return page(games, offset, size), nil
+}
+
+func (c memoryCamp) Create(camp *model.Camp) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	camp.CreatedAt, camp.UpdatedAt = time.Now(), time.Now()
+	c.camps[camp.ID] = *camp
+	return nil
+}
+
+func (c memoryCamp) IncreaseScore(campID uint8) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if camp, ok := c.camps[campID]; ok {
+		camp.Score++
+		c.camps[campID] = camp
+	}
+	return nil
+}
+
+func (c memoryCamp) ListRank(limit int) ([]model.Camp, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	camps := make([]model.Camp, 0, len(c.camps))
+	for _, camp := range c.camps {
+		camps = append(camps, camp)
+	}
+	sort.SliceStable(camps, func(i, j int) bool {
+		if camps[i].Score != camps[j].Score {
+			return camps[i].Score > camps[j].Score
+		}
+		return camps[i].ID < camps[j].ID
+	})
+	return truncate(camps, limit), nil
+}
+
+func (p memoryPlayer) Create(player *model.Player) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if old, ok := p.players[player.PlayerID]; ok {
+		player.CreatedAt = old.CreatedAt
+	} else {
+		player.CreatedAt = time.Now()
+	}
+	player.UpdatedAt = time.Now()
+	p.players[player.PlayerID] = *player
+	return nil
+}
+
+func (p memoryPlayer) Get(playerID uint64) (model.Player, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	player, ok := p.players[playerID]
+	if !ok {
+		return player, gorm.ErrRecordNotFound
+	}
+	return player, nil
+}
+
+func (p memoryPlayer) List(playerIDs ...uint64) ([]model.Player, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	players := []model.Player{}
+	for _, id := range playerIDs {
+		if player, ok := p.players[id]; ok {
+			players = append(players, player)
+		}
+	}
+	return players, nil
+}
+
+func (p memoryPlayer) ListRank(offset, size int) ([]model.Player, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	players := make([]model.Player, 0, len(p.players))
+	for _, player := range p.players {
+		players = append(players, player)
+	}
+	sort.SliceStable(players, func(i, j int) bool {
+		if players[i].Score != players[j].Score {
+			return players[i].Score > players[j].Score
+		}
+		return players[i].PlayerID < players[j].PlayerID
+	})
+	return page(players, offset, size), nil
+}
+
+func (p memoryPlayer) IncreaseScore(gameID uint, campID uint8) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for id, vote := range p.votes[gameID] {
+		if player, ok := p.players[id]; ok && vote.Camp == campID {
+			player.Score++
+			p.players[id] = player
+		}
+	}
+	return nil
+}
+
+func (p memoryPlayer) AddVote(playerVote *model.PlayerVote) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	votes := p.votes[playerVote.GameID]
+	if votes == nil {
+		votes = map[uint64]model.PlayerVote{}
+		p.votes[playerVote.GameID] = votes
+	}
+	if _, ok := votes[playerVote.PlayerID]; ok {
+		return ErrDuplicateVote
+	}
+	votes[playerVote.PlayerID] = *playerVote
+	return nil
+}
+
+func (p memoryPlayer) AddVotes(playerVotes []model.PlayerVote) error {
+	for i := range playerVotes {
+		if err := p.AddVote(&playerVotes[i]); err != nil && err != ErrDuplicateVote {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p memoryPlayer) GetWinnerVotes(gameID uint, winner uint8) int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	var count int64
+	for _, vote := range p.votes[gameID] {
+		if vote.Camp == winner {
+			count++
+		}
+	}
+	return count
+}
+
+func (m memoryMessage) Create(message *model.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messageSeq++
+	message.ID = m.messageSeq
+	message.CreatedAt, message.UpdatedAt = time.Now(), time.Now()
+	m.messages = append(m.messages, *message)
+	return nil
+}
+
+func (m memoryMessage) CreateBatch(messages []model.Message) error {
+	for i := range messages {
+		if err := m.Create(&messages[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m memoryMessage) ListLatest(offset, size int) ([]model.Message, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	messages := []model.Message{}
+	for i := len(m.messages) - 1 - offset; i >= 0 && len(messages) < size; i-- {
+		msg := m.messages[i]
+		msg.Player = m.players[msg.PlayerID]
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func (m memoryDirectMessage) Create(message *model.DirectMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.messageSeq++
+	message.ID = m.messageSeq
+	message.CreatedAt, message.UpdatedAt = time.Now(), time.Now()
+	m.directMessages = append(m.directMessages, *message)
+	return nil
+}
+
+func (m memoryDirectMessage) ListBetween(a, b uint64, offset, size int) ([]model.DirectMessage, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	messages := []model.DirectMessage{}
+	for i := len(m.directMessages) - 1; i >= 0 && len(messages) < size; i-- {
+		msg := m.directMessages[i]
+		if !(msg.FromID == a && msg.ToID == b) && !(msg.FromID == b && msg.ToID == a) {
+			continue
+		}
+		if offset > 0 {
+			offset--
+			continue
+		}
+		msg.From, msg.To = m.players[msg.FromID], m.players[msg.ToID]
+		messages = append(messages, msg)
+	}
+	return messages, nil
+}
+
+func (p memoryPlayer) CountVotes(gameID uint) (map[uint8]int64, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	votes := map[uint8]int64{}
+	for _, vote := range p.votes[gameID] {
+		votes[vote.Camp]++
+	}
+	return votes, nil
+}
+
+func (c memoryCheckpoint) Save(checkpoint *model.Checkpoint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if old, ok := c.checkpoints[checkpoint.GameID]; ok {
+		checkpoint.CreatedAt = old.CreatedAt
+	} else {
+		checkpoint.CreatedAt = now
+	}
+	checkpoint.UpdatedAt = now
+	c.checkpoints[checkpoint.GameID] = *checkpoint
+	return nil
+}
+
+func (c memoryCheckpoint) LatestUnfinished() (model.Checkpoint, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var latest *model.Checkpoint
+	for _, checkpoint := range c.checkpoints {
+		checkpoint := checkpoint
+		game, ok := c.games[checkpoint.GameID]
+		if !ok || game.Settled || game.WinnerID != 0 || checkpoint.Remaining <= 0 {
+			continue
+		}
+		if latest == nil || checkpoint.UpdatedAt.After(latest.UpdatedAt) {
+			latest = &checkpoint
+		}
+	}
+	if latest == nil {
+		return model.Checkpoint{}, gorm.ErrRecordNotFound
+	}
+	return *latest, nil
+}
+
+func (c memoryCheckpoint) Delete(gameID uint) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.checkpoints, gameID)
+	return nil
+}
+
+func truncate[T any](s []T, limit int) []T {
+	if limit >= 0 && len(s) > limit {
+		return s[:limit]
+	}
+	return s
+}
+
+// page returns size elements of s from offset.
+func page[T any](s []T, offset, size int) []T {
+	if offset >= len(s) {
+		return s[:0]
+	}
+	return truncate(s[offset:], size)
+}
@@ -12,6 +12,10 @@ func (m *message) Create(message *model.Message) error {
 	return m.db.Create(message).Error
 }
 
+func (m *message) CreateBatch(messages []model.Message) error {
+	return m.db.Omit(clause.Associations).CreateInBatches(messages, len(messages)).Error
+}
+
 func (m *message) ListLatest(offset, size int) ([]model.Message, error) {
 	var messages []model.Message
 	// if err := m.db.Debug().Model(&model.Message{}).Preload("Player").Order("created_at desc").Offset(offset).Limit(size).Find(&messages).Error; err != nil && err != gorm.ErrRecordNotFound {
@@ -0,0 +1,194 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/model"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Migration is a versioned schema change. Migrations are applied in the order of
+// the migrations slice and recorded in the schema_migrations table; never edit
+// one that has been released, append a new one instead.
+type Migration struct {
+	ID   string
+	Up   func(tx *gorm.DB) error
+	Down func(tx *gorm.DB) error
+}
+
+// ErrSchemaOutdated is returned by CheckSchema when migrations are pending.
+var ErrSchemaOutdated = errors.New("database schema is out of date, run the migrate up command")
+
+type MigrationState struct {
+	ID        string     `json:"id"`
+	AppliedAt *time.Time `json:"applied_at"`
+}
+
+type schemaMigration struct {
+	ID        string `gorm:"primaryKey"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+// Migrate applies the pending migrations.
+func (c *Client) Migrate() error {
+	if c.gdb == nil {
+		return nil
+	}
+	applied, err := c.appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range pendingMigrations(migrations, applied) {
+		err := c.gdb.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{ID: m.ID, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s up: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// Rollback reverts the last steps applied migrations.
+func (c *Client) Rollback(steps int) error {
+	if c.gdb == nil {
+		return nil
+	}
+	applied, err := c.appliedMigrations()
+	if err != nil {
+		return err
+	}
+	for _, m := range rollbackMigrations(migrations, applied, steps) {
+		err := c.gdb.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{ID: m.ID}).Error
+		})
+		if err != nil {
+			return fmt.Errorf("migration %s down: %w", m.ID, err)
+		}
+	}
+	return nil
+}
+
+// CheckSchema fails with ErrSchemaOutdated if a migration is not applied. Unlike
+// Migrate it never changes the database: the server checks the schema, it is
+// migrated on purpose with the migrate command.
+func (c *Client) CheckSchema() error {
+	if c.gdb == nil {
+		return nil
+	}
+	// HasTable doesn't tell a missing table from an unreachable database
+	ctx, cancel := context.WithTimeout(context.Background(), pingTimeout)
+	defer cancel()
+	if err := c.Ping(ctx); err != nil {
+		return err
+	}
+	applied := map[string]time.Time{}
+	if c.gdb.Migrator().HasTable(&schemaMigration{}) {
+		var err error
+		if applied, err = c.readMigrations(); err != nil {
+			return err
+		}
+	}
+	return checkMigrations(migrations, applied)
+}
+
+// checkMigrations returns ErrSchemaOutdated, naming the first pending migration, if one of all is not applied.
+func checkMigrations(all []Migration, applied map[string]time.Time) error {
+	if pending := pendingMigrations(all, applied); len(pending) > 0 {
+		return fmt.Errorf("%w: %d pending, from %s", ErrSchemaOutdated, len(pending), pending[0].ID)
+	}
+	return nil
+}
+
+// pendingMigrations returns the migrations of all that are not applied, in the order they apply.
+func pendingMigrations(all []Migration, applied map[string]time.Time) []Migration {
+	pending := []Migration{}
+	for _, m := range all {
+		if _, ok := applied[m.ID]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// rollbackMigrations returns the last steps applied migrations of all, in the order they revert.
+func rollbackMigrations(all []Migration, applied map[string]time.Time, steps int) []Migration {
+	revert := []Migration{}
+	for i := len(all) - 1; i >= 0 && len(revert) < steps; i-- {
+		if _, ok := applied[all[i].ID]; ok {
+			revert = append(revert, all[i])
+		}
+	}
+	return revert
+}
+
+// MigrationStatus lists every known migration and when it was applied.
+func (c *Client) MigrationStatus() ([]MigrationState, error) {
+	applied := map[string]time.Time{}
+	if c.gdb != nil {
+		var err error
+		if applied, err = c.appliedMigrations(); err != nil {
+			return nil, err
+		}
+	}
+	return migrationStates(migrations, applied), nil
+}
+
+// migrationStates returns the state of every migration of all, in order.
+func migrationStates(all []Migration, applied map[string]time.Time) []MigrationState {
+	states := make([]MigrationState, 0, len(all))
+	for _, m := range all {
+		s := MigrationState{ID: m.ID}
+		if t, ok := applied[m.ID]; ok {
+			s.AppliedAt = &t
+		}
+		states = append(states, s)
+	}
+	return states
+}
+
+// Seed inserts the camps that don't exist yet, existing rows and their scores are left untouched.
+func (c *Client) Seed() error {
+	if c.gdb == nil {
+		return nil
+	}
+	camps := make([]model.Camp, len(model.Camps))
+	copy(camps, model.Camps)
+	return c.gdb.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoNothing: true,
+	}).Create(&camps).Error
+}
+
+func (c *Client) appliedMigrations() (map[string]time.Time, error) {
+	if err := c.gdb.AutoMigrate(&schemaMigration{}); err != nil {
+		return nil, err
+	}
+	return c.readMigrations()
+}
+
+func (c *Client) readMigrations() (map[string]time.Time, error) {
+	var rows []schemaMigration
+	if err := c.gdb.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	applied := make(map[string]time.Time, len(rows))
+	for _, r := range rows {
+		applied[r.ID] = r.AppliedAt
+	}
+	return applied, nil
+}
@@ -0,0 +1,126 @@
+package db
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func ids(ms []Migration) []string {
+	out := []string{}
+	for _, m := range ms {
+		out = append(out, m.ID)
+	}
+	return out
+}
+
+func appliedAt(ids ...string) map[string]time.Time {
+	applied := map[string]time.Time{}
+	for i, id := range ids {
+		applied[id] = time.Unix(int64(i), 0)
+	}
+	return applied
+}
+
+func TestMigrationsAreReversible(t *testing.T) {
+	seen := map[string]bool{}
+	for i, m := range migrations {
+		if m.Up == nil || m.Down == nil {
+			t.Errorf("migration %s has no up or down step", m.ID)
+		}
+		if seen[m.ID] {
+			t.Errorf("migration %s is declared twice", m.ID)
+		}
+		seen[m.ID] = true
+		// the ids sort like the slice, so that the table lists them in order
+		if i > 0 && migrations[i-1].ID >= m.ID {
+			t.Errorf("migration %s is declared after %s", m.ID, migrations[i-1].ID)
+		}
+	}
+}
+
+func TestMigrationOrder(t *testing.T) {
+	all := []Migration{{ID: "0001"}, {ID: "0002"}, {ID: "0003"}, {ID: "0004"}}
+	tests := []struct {
+		name     string
+		applied  map[string]time.Time
+		steps    int
+		up, down []string
+	}{
+		{"fresh database", appliedAt(), 1, []string{"0001", "0002", "0003", "0004"}, []string{}},
+		{"up to date", appliedAt("0001", "0002", "0003", "0004"), 2, []string{}, []string{"0004", "0003"}},
+		{"partly applied", appliedAt("0001", "0002"), 1, []string{"0003", "0004"}, []string{"0002"}},
+		{"gap", appliedAt("0001", "0003"), 5, []string{"0002", "0004"}, []string{"0003", "0001"}},
+		{"no steps", appliedAt("0001"), 0, []string{"0002", "0003", "0004"}, []string{}},
+		{"unknown applied migration", appliedAt("0001", "9999"), 5, []string{"0002", "0003", "0004"}, []string{"0001"}},
+	}
+	for _, tt := range tests {
+		if got := ids(pendingMigrations(all, tt.applied)); !reflect.DeepEqual(got, tt.up) {
+			t.Errorf("%s: up %v, want %v", tt.name, got, tt.up)
+		}
+		if got := ids(rollbackMigrations(all, tt.applied, tt.steps)); !reflect.DeepEqual(got, tt.down) {
+			t.Errorf("%s: down %v, want %v", tt.name, got, tt.down)
+		}
+	}
+}
+
+func TestMigrationStatus(t *testing.T) {
+	all := []Migration{{ID: "0001"}, {ID: "0002"}, {ID: "0003"}}
+	states := migrationStates(all, appliedAt("0001", "0003"))
+	if len(states) != len(all) {
+		t.Fatalf("%d states, want %d", len(states), len(all))
+	}
+	for i, s := range states {
+		if s.ID != all[i].ID {
+			t.Errorf("state %d is %s, want %s", i, s.ID, all[i].ID)
+		}
+		if applied := s.AppliedAt != nil; applied != (s.ID != "0002") {
+			t.Errorf("%s applied=%v", s.ID, applied)
+		}
+	}
+
+	// the in-memory driver has no schema, every migration is pending
+	states, err := NewMemoryClient().MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(states) != len(migrations) {
+		t.Fatalf("%d states, want %d", len(states), len(migrations))
+	}
+	for i, s := range states {
+		if s.ID != migrations[i].ID || s.AppliedAt != nil {
+			t.Errorf("state %d = %+v, want %s pending", i, s, migrations[i].ID)
+		}
+	}
+}
+
+func TestCheckMigrations(t *testing.T) {
+	all := []Migration{{ID: "0001"}, {ID: "0002"}, {ID: "0003"}}
+	tests := []struct {
+		applied map[string]time.Time
+		want    string // first pending migration, empty if the schema is up to date
+	}{
+		{appliedAt(), "0001"},
+		{appliedAt("0001", "0002"), "0003"},
+		{appliedAt("0001", "0003"), "0002"},
+		{appliedAt("0001", "0002", "0003"), ""},
+		// a migration of a newer build doesn't fail an older one
+		{appliedAt("0001", "0002", "0003", "0004"), ""},
+	}
+	for _, tt := range tests {
+		err := checkMigrations(all, tt.applied)
+		switch {
+		case tt.want == "" && err != nil:
+			t.Errorf("applied %v: %v", tt.applied, err)
+		case tt.want != "" && (!errors.Is(err, ErrSchemaOutdated) || !strings.HasSuffix(err.Error(), tt.want)):
+			t.Errorf("applied %v: %v, want ErrSchemaOutdated from %s", tt.applied, err, tt.want)
+		}
+	}
+
+	// the memory client has no schema
+	if err := NewMemoryClient().CheckSchema(); err != nil {
+		t.Errorf("memory client: %v", err)
+	}
+}
@@ -0,0 +1,139 @@
+package db
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var migrations = []Migration{
+	{
+		// adopts the schema previously created by AutoMigrate, it is a no-op on existing databases
+		ID: "0001_initial_schema",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&messageV1{}, &gameV1{}, &playerV1{}, &campV1{}, &playerVoteV1{}, &directMessageV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&messageV1{}, &gameV1{}, &playerV1{}, &campV1{}, &playerVoteV1{}, &directMessageV1{})
+		},
+	},
+	{
+		// rounds are settled once, in a transaction, see game.Settle
+		ID: "0002_game_settlement",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.AddColumn(&gameV2{}, "Settled"); err != nil {
+				return err
+			}
+			if err := m.AddColumn(&gameV2{}, "SettledAt"); err != nil {
+				return err
+			}
+			if err := m.CreateIndex(&gameV2{}, "Settled"); err != nil {
+				return err
+			}
+			// rounds ended before this migration were settled by the previous, non transactional, code
+			return tx.Model(&gameV2{}).Where("end_time < ? AND winner_id <> 0", time.Now()).Update("settled", true).Error
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+			if err := m.DropIndex(&gameV2{}, "Settled"); err != nil {
+				return err
+			}
+			if err := m.DropColumn(&gameV2{}, "SettledAt"); err != nil {
+				return err
+			}
+			return m.DropColumn(&gameV2{}, "Settled")
+		},
+	},
+	{
+		// rounds are checkpointed while they run and resumed after a restart
+		ID: "0003_round_checkpoints",
+		Up: func(tx *gorm.DB) error {
+			return tx.AutoMigrate(&checkpointV1{})
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&checkpointV1{})
+		},
+	},
+}
+
+// Snapshots of the models as of a migration, so that later model changes don't
+// alter what an old migration does.
+
+type playerV1 struct {
+	PlayerID  uint64 `gorm:"primaryKey"`
+	Name      string
+	Score     int
+	Thumbnail string
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+}
+
+func (playerV1) TableName() string { return "players" }
+
+type playerVoteV1 struct {
+	GameID   uint   `gorm:"primarykey;autoIncrement:false"`
+	PlayerID uint64 `gorm:"primarykey;autoIncrement:false"`
+	Camp     uint8  `gorm:"index"`
+}
+
+func (playerVoteV1) TableName() string { return "player_votes" }
+
+type campV1 struct {
+	ID        uint8 `gorm:"primarykey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	DeletedAt gorm.DeletedAt `gorm:"index"`
+	Name      string         `gorm:"uniqueIndex"`
+	ShortName string
+	Icon      string
+	Score     int
+}
+
+func (campV1) TableName() string { return "camps" }
+
+type gameV1 struct {
+	gorm.Model
+	StartTime time.Time
+	EndTime   time.Time
+	WinnerID  uint8
+}
+
+func (gameV1) TableName() string { return "games" }
+
+type gameV2 struct {
+	gameV1
+	Settled   bool `gorm:"not null;default:false;index"`
+	SettledAt *time.Time
+}
+
+func (gameV2) TableName() string { return "games" }
+
+type messageV1 struct {
+	gorm.Model
+	Message  string
+	PlayerID uint64
+}
+
+func (messageV1) TableName() string { return "messages" }
+
+type directMessageV1 struct {
+	gorm.Model
+	Message string
+	FromID  uint64 `gorm:"index"`
+	ToID    uint64 `gorm:"index"`
+}
+
+func (directMessageV1) TableName() string { return "direct_messages" }
+
+type checkpointV1 struct {
+	GameID    uint `gorm:"primarykey;autoIncrement:false"`
+	Frame     uint32
+	Remaining int64
+	State     []byte
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+func (checkpointV1) TableName() string { return "checkpoints" }
@@ -0,0 +1,205 @@
+package db
+
+import (
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/model"
+)
+
+// Observer is told about every call to an accessor of an observed Client.
+type Observer func(accessor, method string, d time.Duration, err error)
+
+// Observe wraps the accessors of c so that observe gets the duration and the
+// error of each call. It must be called before c is shared.
+func (c *Client) Observe(observe Observer) {
+	c.Game = observedGame{c.Game, timer{"game", observe}}
+	c.Camp = observedCamp{c.Camp, timer{"camp", observe}}
+	c.Player = observedPlayer{c.Player, timer{"player", observe}}
+	c.Message = observedMessage{c.Message, timer{"message", observe}}
+	c.DirectMessage = observedDirectMessage{c.DirectMessage, timer{"direct_message", observe}}
+	c.Checkpoint = observedCheckpoint{c.Checkpoint, timer{"checkpoint", observe}}
+}
+
+type timer struct {
+	accessor string
+	observe  Observer
+}
+
+// time reports the call to method that started at start and returned err, it returns err.
+func (t timer) time(method string, start time.Time, err error) error {
+	t.observe(t.accessor, method, time.Since(start), err)
+	return err
+}
+
+type observedGame struct {
+	next GameStore
+	timer
+}
+
+func (s observedGame) Create(game *model.Game) error {
+	start := time.Now()
+	return s.time("create", start, s.next.Create(game))
+}
+
+func (s observedGame) Get(gameID uint) (model.Game, error) {
+	start := time.Now()
+	g, err := s.next.Get(gameID)
+	return g, s.time("get", start, err)
+}
+
+func (s observedGame) Update(game *model.Game) error {
+	start := time.Now()
+	return s.time("update", start, s.next.Update(game))
+}
+
+func (s observedGame) Settle(gameID uint, winnerID uint8, endTime time.Time) error {
+	start := time.Now()
+	return s.time("settle", start, s.next.Settle(gameID, winnerID, endTime))
+}
+
+func (s observedGame) ListUnsettled(before time.Time, limit int) ([]model.Game, error) {
+	start := time.Now()
+	games, err := s.next.ListUnsettled(before, limit)
+	return games, s.time("list_unsettled", start, err)
+}
+
+func (s observedGame) ListHistory(offset, size int) ([]model.Game, error) {
+	start := time.Now()
+	games, err := s.next.ListHistory(offset, size)
+	return games, s.time("list_history", start, err)
+}
+
+type observedCamp struct {
+	next CampStore
+	timer
+}
+
+func (s observedCamp) Create(camp *model.Camp) error {
+	start := time.Now()
+	return s.time("create", start, s.next.Create(camp))
+}
+
+func (s observedCamp) IncreaseScore(campID uint8) error {
+	start := time.Now()
+	return s.time("increase_score", start, s.next.IncreaseScore(campID))
+}
+
+func (s observedCamp) ListRank(limit int) ([]model.Camp, error) {
+	start := time.Now()
+	camps, err := s.next.ListRank(limit)
+	return camps, s.time("list_rank", start, err)
+}
+
+type observedPlayer struct {
+	next PlayerStore
+	timer
+}
+
+func (s observedPlayer) Create(player *model.Player) error {
+	start := time.Now()
+	return s.time("create", start, s.next.Create(player))
+}
+
+func (s observedPlayer) Get(playerID uint64) (model.Player, error) {
+	start := time.Now()
+	p, err := s.next.Get(playerID)
+	return p, s.time("get", start, err)
+}
+
+func (s observedPlayer) List(playerIDs ...uint64) ([]model.Player, error) {
+	start := time.Now()
+	players, err := s.next.List(playerIDs...)
+	return players, s.time("list", start, err)
+}
+
+func (s observedPlayer) ListRank(offset, size int) ([]model.Player, error) {
+	start := time.Now()
+	players, err := s.next.ListRank(offset, size)
+	return players, s.time("list_rank", start, err)
+}
+
+func (s observedPlayer) IncreaseScore(gameID uint, campID uint8) error {
+	start := time.Now()
+	return s.time("increase_score", start, s.next.IncreaseScore(gameID, campID))
+}
+
+func (s observedPlayer) AddVote(playerVote *model.PlayerVote) error {
+	start := time.Now()
+	return s.time("add_vote", start, s.next.AddVote(playerVote))
+}
+
+func (s observedPlayer) AddVotes(playerVotes []model.PlayerVote) error {
+	start := time.Now()
+	return s.time("add_votes", start, s.next.AddVotes(playerVotes))
+}
+
+func (s observedPlayer) GetWinnerVotes(gameID uint, winner uint8) int64 {
+	start := time.Now()
+	n := s.next.GetWinnerVotes(gameID, winner)
+	s.time("get_winner_votes", start, nil)
+	return n
+}
+
+func (s observedPlayer) CountVotes(gameID uint) (map[uint8]int64, error) {
+	start := time.Now()
+	votes, err := s.next.CountVotes(gameID)
+	return votes, s.time("count_votes", start, err)
+}
+
+type observedMessage struct {
+	next MessageStore
+	timer
+}
+
+func (s observedMessage) Create(message *model.Message) error {
+	start := time.Now()
+	return s.time("create", start, s.next.Create(message))
+}
+
+func (s observedMessage) CreateBatch(messages []model.Message) error {
+	start := time.Now()
+	return s.time("create_batch", start, s.next.CreateBatch(messages))
+}
+
+func (s observedMessage) ListLatest(offset, size int) ([]model.Message, error) {
+	start := time.Now()
+	messages, err := s.next.ListLatest(offset, size)
+	return messages, s.time("list_latest", start, err)
+}
+
+type observedDirectMessage struct {
+	next DirectMessageStore
+	timer
+}
+
+func (s observedDirectMessage) Create(message *model.DirectMessage) error {
+	start := time.Now()
+	return s.time("create", start, s.next.Create(message))
+}
+
+func (s observedDirectMessage) ListBetween(a, b uint64, offset, size int) ([]model.DirectMessage, error) {
+	start := time.Now()
+	messages, err := s.next.ListBetween(a, b, offset, size)
+	return messages, s.time("list_between", start, err)
+}
+
+type observedCheckpoint struct {
+	next CheckpointStore
+	timer
+}
+
+func (s observedCheckpoint) Save(checkpoint *model.Checkpoint) error {
+	start := time.Now()
+	return s.time("save", start, s.next.Save(checkpoint))
+}
+
+func (s observedCheckpoint) LatestUnfinished() (model.Checkpoint, error) {
+	start := time.Now()
+	c, err := s.next.LatestUnfinished()
+	return c, s.time("latest_unfinished", start, err)
+}
+
+func (s observedCheckpoint) Delete(gameID uint) error {
+	start := time.Now()
+	return s.time("delete", start, s.next.Delete(gameID))
+}
@@ -27,9 +27,9 @@ func (p *player) List(playeIDs ...uint64) ([]model.Player, error) {
 	return players, err
 }
 
-func (p *player) ListRank(limit int) ([]model.Player, error) {
+func (p *player) ListRank(offset, size int) ([]model.Player, error) {
 	var players []model.Player
-	err := p.db.Order("score desc").Limit(limit).Find(&players).Error
+	err := p.db.Order("score desc, player_id").Offset(offset).Limit(size).Find(&players).Error
 	return players, err
 }
 
@@ -49,8 +49,27 @@ func (p *player) AddVote(playerVotes *model.PlayerVote) error {
 	return p.db.Create(playerVotes).Error
 }
 
+// AddVotes inserts the votes, skipping those of players who already voted in the game.
+func (p *player) AddVotes(playerVotes []model.PlayerVote) error {
+	return p.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(playerVotes, len(playerVotes)).Error
+}
+
 func (p *player) GetWinnerVotes(gameId uint, winner uint8) int64 {
 	var count int64
 	p.db.Model(&model.PlayerVote{}).Where("game_id = ? AND camp = ?", gameId, winner).Count(&count)
 	return count
 }
+
+// CountVotes returns the number of votes for each camp in a round.
+func (p *player) CountVotes(gameID uint) (map[uint8]int64, error) {
+	var rows []struct {
+		Camp  uint8
+		Count int64
+	}
+	err := p.db.Model(&model.PlayerVote{}).Select("camp, count(*) as count").Where("game_id = ?", gameID).Group("camp").Scan(&rows).Error
+	votes := make(map[uint8]int64, len(rows))
+	for _, r := range rows {
+		votes[r.Camp] = r.Count
+	}
+	return votes, err
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/model"
+)
+
+// The accessors of a Client. The GORM implementations live next to this file,
+// memory.go holds the in-memory ones used without a database.
+
+type GameStore interface {
+	Create(game *model.Game) error
+	Get(gameID uint) (model.Game, error)
+	Update(game *model.Game) error
+	Settle(gameID uint, winnerID uint8, endTime time.Time) error
+	ListUnsettled(before time.Time, limit int) ([]model.Game, error)
+	ListHistory(offset, size int) ([]model.Game, error)
+}
+
+type CampStore interface {
+	Create(camp *model.Camp) error
+	IncreaseScore(campID uint8) error
+	ListRank(limit int) ([]model.Camp, error)
+}
+
+type PlayerStore interface {
+	Create(player *model.Player) error
+	Get(playerID uint64) (model.Player, error)
+	List(playerIDs ...uint64) ([]model.Player, error)
+	ListRank(offset, size int) ([]model.Player, error)
+	IncreaseScore(gameID uint, campID uint8) error
+	AddVote(playerVote *model.PlayerVote) error
+	AddVotes(playerVotes []model.PlayerVote) error
+	GetWinnerVotes(gameID uint, winner uint8) int64
+	CountVotes(gameID uint) (map[uint8]int64, error)
+}
+
+type MessageStore interface {
+	Create(message *model.Message) error
+	CreateBatch(messages []model.Message) error
+	ListLatest(offset, size int) ([]model.Message, error)
+}
+
+type DirectMessageStore interface {
+	Create(message *model.DirectMessage) error
+	ListBetween(a, b uint64, offset, size int) ([]model.DirectMessage, error)
+}
+
+type CheckpointStore interface {
+	Save(checkpoint *model.Checkpoint) error
+	LatestUnfinished() (model.Checkpoint, error)
+	Delete(gameID uint) error
+}
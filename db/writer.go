@@ -0,0 +1,325 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/model"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultWriteQueueSize     = 10000
+	defaultWriteBatchSize     = 200
+	defaultWriteFlushInterval = 200 // milliseconds
+
+	writeLagWarning = time.Second
+)
+
+// ErrWritesPending is returned by Flush when writes wait for the database to come back.
+var ErrWritesPending = errors.New("writes pending until the database is back")
+
+type WriterConfig struct {
+	QueueSize     int `json:"queue_size"`
+	BatchSize     int `json:"batch_size"`
+	FlushInterval int `json:"flush_interval"` // milliseconds
+}
+
+// WriterStats are the counters of a Writer. Lag is how long the last flushed
+// batch waited in the queue, Pending how many writes wait for the database to come back.
+type WriterStats struct {
+	Queued  int64         `json:"queued"`
+	Pending int64         `json:"pending"`
+	Written int64         `json:"written"`
+	Dropped int64         `json:"dropped"`
+	Failed  int64         `json:"failed"`
+	Lag     time.Duration `json:"lag"`
+}
+
+// GameRef resolves the id of a game row when the write is flushed, the row
+// may itself be created by an op queued earlier.
+type GameRef func() uint
+
+type write struct {
+	message *model.Message
+	vote    *model.PlayerVote
+	game    GameRef
+	op      func(*Client) error
+	flushed chan error // a Flush barrier, answered once the writes before it are flushed
+	at      time.Time
+}
+
+// Writer persists chat messages and votes in the background, in batches, so that
+// database latency doesn't stall the handlers. Its queue is bounded: when it is
+// full, writes are dropped and counted rather than blocking the caller.
+//
+// While the database is unavailable, writes are kept, in order and up to the
+// queue size, and replayed once it is back.
+type Writer struct {
+	client        *Client
+	queue         chan write
+	queueSize     int
+	batchSize     int
+	flushInterval time.Duration
+
+	pending []write // owned by run
+
+	pendingLen int64
+	written    int64
+	dropped    int64
+	failed     int64
+	lag        int64
+
+	// closed is set, and stop closed, once by Close; enqueue checks closed under mu
+	// so that no write is queued after run drained the queue
+	mu        sync.RWMutex
+	closed    bool
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+func NewWriter(client *Client, cfg WriterConfig) *Writer {
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultWriteQueueSize
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultWriteBatchSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultWriteFlushInterval
+	}
+	w := &Writer{
+		client:        client,
+		queue:         make(chan write, cfg.QueueSize),
+		queueSize:     cfg.QueueSize,
+		batchSize:     cfg.BatchSize,
+		flushInterval: time.Duration(cfg.FlushInterval) * time.Millisecond,
+		stop:          make(chan struct{}),
+		done:          make(chan struct{}),
+	}
+	go w.run()
+	return w
+}
+
+// SaveMessage queues msg for insertion, it reports false if the queue is full.
+func (w *Writer) SaveMessage(msg model.Message) bool {
+	return w.enqueue(write{message: &msg})
+}
+
+// AddVote queues vote for insertion in the game game resolves to, duplicate votes are ignored.
+func (w *Writer) AddVote(game GameRef, vote model.PlayerVote) bool {
+	return w.enqueue(write{vote: &vote, game: game})
+}
+
+// Do queues an arbitrary write, it runs after the writes queued before it.
+func (w *Writer) Do(op func(*Client) error) bool {
+	return w.enqueue(write{op: op})
+}
+
+// Flush waits until the writes queued before it reached the database. It returns
+// ErrWritesPending if some are kept for replay, or ctx's error if it is done first.
+func (w *Writer) Flush(ctx context.Context) error {
+	wr := write{flushed: make(chan error, 1), at: time.Now()}
+	w.mu.RLock()
+	if w.closed {
+		w.mu.RUnlock()
+		// Close flushes what was queued, there is nothing to wait for after it
+		select {
+		case <-w.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		if atomic.LoadInt64(&w.pendingLen) > 0 {
+			return ErrWritesPending
+		}
+		return nil
+	}
+	// unlike a write, a barrier waits for room in the queue
+	select {
+	case w.queue <- wr:
+		w.mu.RUnlock()
+	case <-ctx.Done():
+		w.mu.RUnlock()
+		return ctx.Err()
+	}
+	select {
+	case err := <-wr.flushed:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Writer) enqueue(wr write) bool {
+	wr.at = time.Now()
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if w.closed {
+		atomic.AddInt64(&w.dropped, 1)
+		zap.L().Warn("writer closed, dropping write")
+		return false
+	}
+	select {
+	case w.queue <- wr:
+		return true
+	default:
+		atomic.AddInt64(&w.dropped, 1)
+		zap.L().Warn("write queue full, dropping write")
+		return false
+	}
+}
+
+func (w *Writer) Stats() WriterStats {
+	return WriterStats{
+		Queued:  int64(len(w.queue)),
+		Pending: atomic.LoadInt64(&w.pendingLen),
+		Written: atomic.LoadInt64(&w.written),
+		Dropped: atomic.LoadInt64(&w.dropped),
+		Failed:  atomic.LoadInt64(&w.failed),
+		Lag:     time.Duration(atomic.LoadInt64(&w.lag)),
+	}
+}
+
+// Close flushes the queued writes and stops the writer, it gives up when ctx is done.
+// Writes queued after Close are dropped.
+func (w *Writer) Close(ctx context.Context) error {
+	w.closeOnce.Do(func() {
+		w.mu.Lock()
+		w.closed = true
+		w.mu.Unlock()
+		close(w.stop)
+	})
+	select {
+	case <-w.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (w *Writer) run() {
+	defer close(w.done)
+	ticker := time.NewTicker(w.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]write, 0, w.batchSize)
+	for {
+		select {
+		case wr := <-w.queue:
+			if wr.flushed != nil {
+				w.flush(batch)
+				batch = batch[:0]
+				wr.flushed <- w.pendingErr()
+				continue
+			}
+			if batch = append(batch, wr); len(batch) >= w.batchSize {
+				w.flush(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			w.flush(batch)
+			batch = batch[:0]
+		case <-w.stop:
+			// nothing is queued once stop is closed
+			barriers := []chan error{}
+			for n := len(w.queue); n > 0; n-- {
+				if wr := <-w.queue; wr.flushed != nil {
+					barriers = append(barriers, wr.flushed)
+				} else {
+					batch = append(batch, wr)
+				}
+			}
+			w.flush(batch)
+			for _, b := range barriers {
+				b <- w.pendingErr()
+			}
+			return
+		}
+	}
+}
+
+// flush writes the pending writes then batch, in order. Consecutive messages and
+// votes are inserted in bulk. It stops at the first failure caused by an unavailable
+// database and keeps the rest for the next flush.
+func (w *Writer) flush(batch []write) {
+	writes := append(w.pending, batch...)
+	w.pending = nil
+	if len(writes) == 0 {
+		return
+	}
+	if w.client.Degraded() {
+		w.keep(writes)
+		return
+	}
+
+	for i := 0; i < len(writes); {
+		j := i + 1
+		var err error
+		switch wr := writes[i]; {
+		case wr.message != nil:
+			for j < len(writes) && writes[j].message != nil {
+				j++
+			}
+			messages := make([]model.Message, 0, j-i)
+			for _, m := range writes[i:j] {
+				messages = append(messages, *m.message)
+			}
+			err = w.client.Message.CreateBatch(messages)
+		case wr.vote != nil:
+			for j < len(writes) && writes[j].vote != nil {
+				j++
+			}
+			votes := make([]model.PlayerVote, 0, j-i)
+			for _, v := range writes[i:j] {
+				if v.game != nil {
+					v.vote.GameID = v.game()
+				}
+				votes = append(votes, *v.vote)
+			}
+			err = w.client.Player.AddVotes(votes)
+		case wr.op != nil:
+			err = wr.op(w.client)
+		}
+
+		if err != nil {
+			if w.client.Check(); w.client.Degraded() {
+				zap.L().Warn("database unavailable, keeping writes for replay", zap.Int("count", len(writes)-i), zap.Error(err))
+				w.keep(writes[i:])
+				return
+			}
+			atomic.AddInt64(&w.failed, int64(j-i))
+			zap.L().Error("write failed", zap.Int("count", j-i), zap.Error(err))
+		} else {
+			atomic.AddInt64(&w.written, int64(j-i))
+		}
+		i = j
+	}
+	atomic.StoreInt64(&w.pendingLen, 0)
+
+	lag := time.Since(writes[0].at)
+	atomic.StoreInt64(&w.lag, int64(lag))
+	if lag > writeLagWarning {
+		zap.L().Warn("write-behind lagging", zap.Duration("lag", lag), zap.Int("queued", len(w.queue)))
+	}
+}
+
+func (w *Writer) pendingErr() error {
+	if len(w.pending) > 0 {
+		return ErrWritesPending
+	}
+	return nil
+}
+
+// keep retains writes for replay, dropping the oldest beyond the queue size.
+func (w *Writer) keep(writes []write) {
+	if over := len(writes) - w.queueSize; over > 0 {
+		atomic.AddInt64(&w.dropped, int64(over))
+		writes = writes[over:]
+	}
+	w.pending = append([]write(nil), writes...)
+	atomic.StoreInt64(&w.pendingLen, int64(len(w.pending)))
+}
@@ -0,0 +1,113 @@
+package db
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/model"
+)
+
+func TestWriterClose(t *testing.T) {
+	c := NewMemoryClient()
+	// a long flush interval: the writes are only flushed by Close
+	w := NewWriter(c, WriterConfig{FlushInterval: 60 * 1000})
+	for i := 0; i < 10; i++ {
+		if !w.SaveMessage(model.Message{PlayerID: uint64(i), Message: "hi"}) {
+			t.Fatalf("write %d refused before Close", i)
+		}
+	}
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if messages, _ := c.Message.ListLatest(0, 100); len(messages) != 10 {
+		t.Errorf("%d messages written, want 10", len(messages))
+	}
+
+	// writes racing with or following Close are refused, not sent on a closed queue
+	if w.SaveMessage(model.Message{PlayerID: 1, Message: "late"}) {
+		t.Error("a message was queued after Close")
+	}
+	if w.AddVote(func() uint { return 1 }, model.PlayerVote{PlayerID: 1, Camp: 1}) {
+		t.Error("a vote was queued after Close")
+	}
+	if w.Do(func(*Client) error { return nil }) {
+		t.Error("an op was queued after Close")
+	}
+	if err := w.Close(context.Background()); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if s := w.Stats(); s.Dropped != 3 || s.Written != 10 {
+		t.Errorf("stats %+v, want 3 dropped and 10 written", s)
+	}
+}
+
+func TestWriterCloseConcurrent(t *testing.T) {
+	c := NewMemoryClient()
+	w := NewWriter(c, WriterConfig{})
+	var wg sync.WaitGroup
+	queued := make([]int, 8)
+	for g := range queued {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for i := 0; i < 200; i++ {
+				if w.SaveMessage(model.Message{PlayerID: uint64(g), Message: "hi"}) {
+					queued[g]++
+				}
+			}
+		}(g)
+	}
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, n := range queued {
+		total += n
+	}
+	// every write accepted before Close is flushed
+	if messages, _ := c.Message.ListLatest(0, 10000); len(messages) != total {
+		t.Errorf("%d messages written, %d queued", len(messages), total)
+	}
+}
+
+func TestWriterFlush(t *testing.T) {
+	c := NewMemoryClient()
+	w := NewWriter(c, WriterConfig{FlushInterval: 60 * 1000})
+	for i := 0; i < 3; i++ {
+		w.SaveMessage(model.Message{PlayerID: uint64(i), Message: "hi"})
+	}
+	if err := w.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if messages, _ := c.Message.ListLatest(0, 100); len(messages) != 3 {
+		t.Errorf("%d messages written after Flush, want 3", len(messages))
+	}
+
+	// while the database is down the writes are kept, Flush says so
+	atomic.StoreInt32(&c.health.degraded, 1)
+	w.SaveMessage(model.Message{PlayerID: 4, Message: "hi"})
+	if err := w.Flush(context.Background()); err != ErrWritesPending {
+		t.Errorf("Flush while degraded: %v, want %v", err, ErrWritesPending)
+	}
+	atomic.StoreInt32(&c.health.degraded, 0)
+	if err := w.Flush(context.Background()); err != nil {
+		t.Errorf("Flush once the database is back: %v", err)
+	}
+	if messages, _ := c.Message.ListLatest(0, 100); len(messages) != 4 {
+		t.Errorf("%d messages written, want 4", len(messages))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := w.Close(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(ctx); err != nil {
+		t.Errorf("Flush after Close: %v", err)
+	}
+}
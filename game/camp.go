@@ -57,35 +57,6 @@ var (
 	}
 )
 
-func getCollisionTags(camp Camp) (retval []string) {
-	switch camp {
-	case BTC:
-		retval = []string{CampTagMap[ETH], CampTagMap[BNB], CampTagMap[AVAX], CampTagMap[MATIC], CampTagMap[Empty]}
-	case ETH:
-		retval = []string{CampTagMap[BNB], CampTagMap[BTC], CampTagMap[AVAX], CampTagMap[MATIC], CampTagMap[Empty]}
-	case BNB:
-		retval = []string{CampTagMap[ETH], CampTagMap[BTC], CampTagMap[AVAX], CampTagMap[MATIC], CampTagMap[Empty]}
-	case AVAX:
-		retval = []string{CampTagMap[ETH], CampTagMap[BNB], CampTagMap[BTC], CampTagMap[MATIC], CampTagMap[Empty]}
-	case MATIC:
-		retval = []string{CampTagMap[ETH], CampTagMap[BNB], CampTagMap[BTC], CampTagMap[AVAX], CampTagMap[Empty]}
-	default:
-		retval = []string{CampTagMap[BTC], CampTagMap[ETH], CampTagMap[BNB], CampTagMap[AVAX], CampTagMap[MATIC], CampTagMap[Empty]}
-	}
-	retval = append(retval, EdgeTag, ItemTag)
-	return
-}
-
-func removeCampTags(tags []string) []string {
-	ret := []string{}
-	for _, tag := range tags {
-		if _, ok := CampTagMapReverse[tag]; ok {
-			ret = append(ret, tag)
-		}
-	}
-	return ret
-}
-
 func initCamp(x, y int) Camp {
 	camp := Empty
 	for c := range CampTagMap {
@@ -0,0 +1,56 @@
+package game
+
+import (
+	"math"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"go.uber.org/zap"
+)
+
+// CasterFrame is pushed, as onCasterUpdate, to the casters and moderators along with
+// every frame. Unlike onUpdate it names the balls and carries stats kept from players.
+type CasterFrame struct {
+	Frame  uint32         `json:"frame"`
+	Balls  []CasterBall   `json:"balls"`
+	Cells  map[Camp]int   `json:"cells"`
+	Votes  map[Camp]int32 `json:"votes"`
+	Cheers map[Camp]int32 `json:"cheers"`
+}
+
+type CasterBall struct {
+	PlayerState
+	Name  string  `json:"name"`
+	Speed float64 `json:"speed"`
+}
+
+// casterFrame enriches snap for the casters.
+func (r *Room) casterFrame(snap *Snapshot) CasterFrame {
+	f := CasterFrame{
+		Frame:  snap.Frame,
+		Balls:  make([]CasterBall, 0, len(snap.Players)),
+		Cells:  map[Camp]int{},
+		Votes:  snap.CampVotes,
+		Cheers: r.game.CampCheers(),
+	}
+	// the names come with the balls, a frame never waits on the database
+	for _, p := range snap.Players {
+		f.Balls = append(f.Balls, CasterBall{PlayerState: p, Name: r.game.playerName(p.ID), Speed: math.Hypot(p.Vx, p.Vy)})
+	}
+	for _, c := range snap.Cells {
+		if c != Empty {
+			f.Cells[c]++
+		}
+	}
+	return f
+}
+
+// broadcastCasterFrame sends snap to the caster group, if anyone is in it.
+func (r *Room) broadcastCasterFrame(snap *Snapshot) {
+	n, err := r.app.GroupCountMembers(r.ctx, config.CasterGroupName)
+	if err != nil || n == 0 {
+		return
+	}
+	if err := r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.CasterGroupName, "onCasterUpdate", r.casterFrame(snap)); err != nil {
+		r.game.log().Error("broadcast caster frame failed", zap.Error(err))
+	}
+}
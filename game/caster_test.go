@@ -0,0 +1,32 @@
+package game
+
+import "testing"
+
+func TestCasterFrameNames(t *testing.T) {
+	g := newTestGame()
+	// no database: building a frame must not query it
+	r := &Room{game: g}
+	g.AddPlayer(1, "alice", BTC, nil)
+	g.AddPlayer(2, "bob", ETH, nil)
+	// already in the arena, the first name stays
+	g.AddPlayer(1, "mallory", ETH, nil)
+	g.Step()
+
+	names := map[uint64]string{}
+	for _, b := range r.casterFrame(g.Snapshot()).Balls {
+		names[b.ID] = b.Name
+	}
+	if len(names) != 2 || names[1] != "alice" || names[2] != "bob" {
+		t.Errorf("caster names %v, want alice and bob", names)
+	}
+
+	g.Reset()
+	g.AddPlayer(3, "carol", BNB, nil)
+	g.Step()
+	if name := g.playerName(1); name != "" {
+		t.Errorf("player 1 still named %q after the round", name)
+	}
+	if f := r.casterFrame(g.Snapshot()); len(f.Balls) != 1 || f.Balls[0].Name != "carol" {
+		t.Errorf("caster balls %+v in the next round, want carol", f.Balls)
+	}
+}
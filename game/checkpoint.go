@@ -0,0 +1,141 @@
+package game
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/model"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// checkpointState is the simulation state stored in a checkpoint.
+type checkpointState struct {
+	Cells   []Camp         `json:"cells"`
+	Players []PlayerState  `json:"players"`
+	Items   []ItemState    `json:"items"`
+	Votes   map[Camp]int32 `json:"votes"`
+	Cheers  map[Camp]int32 `json:"cheers"`
+	ItemSeq uint32         `json:"item_seq"`
+}
+
+// remaining returns the time left in the round.
+func (g *Game) remaining() time.Duration {
+	if g.GameStatus == GamePaused {
+		return g.roundRemain
+	}
+	return time.Until(g.roundDeadline)
+}
+
+// checkpoint queues a snapshot of the round for the writer, it runs on the game loop.
+func (g *Game) checkpoint() {
+	if g.GameStatus != GameRunning && g.GameStatus != GamePaused {
+		return
+	}
+	snap := g.snapshot()
+	state := checkpointState{
+		Cells:   snap.Cells,
+		Players: snap.Players,
+		Items:   snap.Items,
+		Votes:   snap.CampVotes,
+		Cheers:  g.CampCheers(),
+		ItemSeq: g.itemSeq,
+	}
+	b, err := json.Marshal(state)
+	if err != nil {
+		g.log().Error("failed to encode checkpoint", zap.Error(err))
+		return
+	}
+
+	ref := g.GameRef()
+	checkpoint := &model.Checkpoint{Frame: snap.Frame, Remaining: g.remaining().Milliseconds(), State: b}
+	g.writer.Do(func(c *db.Client) error {
+		if checkpoint.GameID = ref(); checkpoint.GameID == 0 {
+			return nil
+		}
+		return c.Checkpoint.Save(checkpoint)
+	})
+}
+
+// dropCheckpoint deletes the checkpoint of a round that ended.
+func (g *Game) dropCheckpoint() {
+	ref := g.GameRef()
+	g.writer.Do(func(c *db.Client) error {
+		if id := ref(); id != 0 {
+			return c.Checkpoint.Delete(id)
+		}
+		return nil
+	})
+}
+
+// resume restores the latest unfinished round, it returns false if there is none.
+func (g *Game) resume() bool {
+	checkpoint, err := g.db.Checkpoint.LatestUnfinished()
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			roomLog().Error("failed to load checkpoint, starting a new round", zap.Error(err))
+		}
+		return false
+	}
+	gm, err := g.db.Game.Get(checkpoint.GameID)
+	if err != nil {
+		roundLog(checkpoint.GameID).Error("failed to load checkpointed game, starting a new round", zap.Error(err))
+		return false
+	}
+	var state checkpointState
+	if err := json.Unmarshal(checkpoint.State, &state); err != nil {
+		roundLog(checkpoint.GameID).Error("failed to decode checkpoint, starting a new round", zap.Error(err))
+		return false
+	}
+	if len(state.Cells) != mapRow*mapColumn {
+		roundLog(checkpoint.GameID).Error("checkpoint doesn't match the map, starting a new round", zap.Int("cells", len(state.Cells)))
+		return false
+	}
+
+	g.initMapWith(state.Cells)
+	ids := make([]uint64, 0, len(state.Players))
+	for _, p := range state.Players {
+		g.players[p.ID] = &Player{ID: p.ID, Camp: p.Camp, R: p.R, Vx: p.Vx, Vy: p.Vy, x: p.X, y: p.Y, taken: p.Taken}
+		ids = append(ids, p.ID)
+	}
+	// the names are only known when the players join, the resumed balls get theirs now
+	if len(ids) > 0 {
+		players, err := g.db.Player.List(ids...)
+		if err != nil {
+			roundLog(checkpoint.GameID).Warn("failed to load the names of the resumed players", zap.Error(err))
+		}
+		for _, p := range players {
+			g.names.Store(p.PlayerID, p.Name)
+		}
+	}
+	for _, i := range state.Items {
+		item, ok := ItemMap[i.Type]
+		if !ok {
+			continue
+		}
+		g.items[i.ID] = &ItemObject{Id: i.ID, X: i.X, Y: i.Y, Item: item}
+	}
+	for camp, votes := range state.Votes {
+		g.campVotes[camp] = votes
+	}
+	for camp, cheers := range state.Cheers {
+		if camp > Empty && camp <= MATIC {
+			g.campCheers[camp] = cheers
+		}
+	}
+	g.itemSeq = state.ItemSeq
+	g.frameNumber = checkpoint.Frame
+
+	// the time the server was down doesn't count
+	g.roundRemain = time.Duration(checkpoint.Remaining) * time.Millisecond
+	gm.EndTime = time.Now().Add(g.roundRemain)
+	g.dbGameMu.Lock()
+	g.dbGame = &gm
+	g.dbGameMu.Unlock()
+	g.updateGame()
+	roundLog(gm.ID).Info("round resumed from checkpoint", zap.Duration("remaining", g.roundRemain),
+		zap.Int("players", len(state.Players)), zap.Duration("age", time.Since(checkpoint.UpdatedAt)))
+	return true
+}
@@ -0,0 +1,130 @@
+package game
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultReaction        = "👏"
+	maxReactionLen         = 16
+	lastCheerKey           = "last_cheer"
+	cheerCooldown          = 200 * time.Millisecond
+	cheerBroadcastInterval = time.Second
+
+	// cheerItemSpread is how far, in cells, from a ball a cheer item may drop.
+	cheerItemSpread = 3
+)
+
+var (
+	errInvalidCamp     = errors.New("invalid camp")
+	errInvalidReaction = errors.New("invalid reaction")
+	errCheerTooFast    = errors.New("cheer too fast")
+)
+
+type CheerRequest struct {
+	Camp     Camp   `json:"camp"`
+	Reaction string `json:"reaction"`
+}
+
+type CheerResponse struct {
+	Code   int    `json:"code"`
+	Result string `json:"result"`
+	Cheers int32  `json:"cheers"`
+}
+
+// CampCheers is broadcast every cheerBroadcastInterval while viewers are cheering.
+// Cheers holds the round totals, Reactions the reactions received since the last broadcast.
+type CampCheers struct {
+	Cheers    map[Camp]int32            `json:"cheers"`
+	Reactions map[Camp]map[string]int32 `json:"reactions"`
+}
+
+// cheerBoard aggregates reactions between two broadcasts.
+type cheerBoard struct {
+	mu        sync.Mutex
+	reactions map[Camp]map[string]int32
+}
+
+// Cheer records a viewer cheer for camp and returns the camp's cheers in this round.
+// Every cfg.CheersPerItem cheers, an accelerator is dropped near one of the camp's balls.
+func (g *Game) Cheer(camp Camp) int32 {
+	if camp <= Empty || camp > MATIC {
+		return 0
+	}
+	n := atomic.AddInt32(&g.campCheers[camp], 1)
+	if perItem := g.Config().CheersPerItem; perItem > 0 && n%int32(perItem) == 0 {
+		g.submit(func() {
+			if g.GameStatus == GameRunning {
+				g.spawnItemNear(camp)
+			}
+		})
+	}
+	return n
+}
+
+// CampCheers returns the cheers of every camp in this round.
+func (g *Game) CampCheers() map[Camp]int32 {
+	cheers := map[Camp]int32{}
+	for camp := range g.campCheers {
+		if n := atomic.LoadInt32(&g.campCheers[camp]); n > 0 {
+			cheers[Camp(camp)] = n
+		}
+	}
+	return cheers
+}
+
+// spawnItemNear drops an accelerator a few cells away from a random ball of camp,
+// or from the camp's spawn point if it has no ball in the arena yet.
+func (g *Game) spawnItemNear(camp Camp) {
+	balls := []*Player{}
+	for _, p := range g.players {
+		if p.Camp == camp {
+			balls = append(balls, p)
+		}
+	}
+	sort.Slice(balls, func(i, j int) bool { return balls[i].ID < balls[j].ID })
+
+	cx, cy := camp.CenterCellIndex(mapRow, mapColumn)
+	if len(balls) > 0 {
+		x, y := space2MapXY(balls[g.rnd.Intn(len(balls))].GetCenter())
+		cx, cy = int(x)/(cellWidth+lineWidth), int(y)/(cellHeight+lineWidth)
+	}
+	cx = clampInt(cx+g.rnd.Intn(2*cheerItemSpread+1)-cheerItemSpread, 0, mapColumn-1)
+	cy = clampInt(cy+g.rnd.Intn(2*cheerItemSpread+1)-cheerItemSpread, 0, mapRow-1)
+	x, y := cellIndexToSpaceXY(cx, cy)
+	g.addItem(x, y, ItemAccelerator)
+}
+
+func clampInt(v, lo, hi int) int {
+	if v < lo {
+		return lo
+	}
+	if v > hi {
+		return hi
+	}
+	return v
+}
+
+func (b *cheerBoard) add(camp Camp, reaction string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.reactions == nil {
+		b.reactions = map[Camp]map[string]int32{}
+	}
+	if b.reactions[camp] == nil {
+		b.reactions[camp] = map[string]int32{}
+	}
+	b.reactions[camp][reaction]++
+}
+
+func (b *cheerBoard) flush() (map[Camp]map[string]int32, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	reactions := b.reactions
+	b.reactions = nil
+	return reactions, len(reactions) > 0
+}
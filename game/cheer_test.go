@@ -0,0 +1,140 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/role"
+	"github.com/topfreegames/pitaya/v2"
+	pitayaerrors "github.com/topfreegames/pitaya/v2/errors"
+	"github.com/topfreegames/pitaya/v2/session"
+)
+
+// testApp serves the session of the handler and records the broadcasts, the
+// handlers under test don't call the rest of pitaya.Pitaya.
+type testApp struct {
+	pitaya.Pitaya
+	s      session.Session
+	routes []string
+}
+
+func (a *testApp) GetSessionFromCtx(context.Context) session.Session {
+	return a.s
+}
+
+func (a *testApp) GroupBroadcast(_ context.Context, _, _, route string, _ interface{}) error {
+	a.routes = append(a.routes, route)
+	return nil
+}
+
+// errorCode returns the pitaya code of err, empty if it has none.
+func errorCode(err error) string {
+	var perr *pitayaerrors.Error
+	if errors.As(err, &perr) {
+		return perr.Code
+	}
+	return ""
+}
+
+func TestCheer(t *testing.T) {
+	g := newTestGame()
+	cfg := *g.Config()
+	cfg.CheersPerItem = 3
+	g.cfg.Store(&cfg)
+
+	for i := 1; i <= 7; i++ {
+		if n := g.Cheer(ETH); n != int32(i) {
+			t.Fatalf("cheer %d counted %d", i, n)
+		}
+	}
+	g.Cheer(BTC)
+	for _, camp := range []Camp{Empty, MATIC + 1} {
+		if n := g.Cheer(camp); n != 0 {
+			t.Errorf("cheer for camp %d counted %d", camp, n)
+		}
+	}
+	if cheers := g.CampCheers(); len(cheers) != 2 || cheers[ETH] != 7 || cheers[BTC] != 1 {
+		t.Errorf("cheers %v, want ETH 7 and BTC 1", cheers)
+	}
+
+	// an item every 3 cheers of a camp, near its spawn point as it has no ball
+	g.Step()
+	if len(g.items) != 2 {
+		t.Fatalf("%d items after 7 cheers, want 2", len(g.items))
+	}
+	cx, cy := ETH.CenterCellIndex(mapRow, mapColumn)
+	x0, y0 := cellIndexToSpaceXY(cx, cy)
+	spread := float64(cheerItemSpread * (cellWidth + lineWidth))
+	for _, item := range g.items {
+		if item.Item.Type != ItemAccelerator || math.Abs(item.X-x0) > spread || math.Abs(item.Y-y0) > spread {
+			t.Errorf("item %+v, want an accelerator within %d cells of %v, %v", item, cheerItemSpread, x0, y0)
+		}
+	}
+
+	// no item drops between rounds, the cheers still count
+	g.GameStatus = GameStopped
+	for i := 0; i < 3; i++ {
+		g.Cheer(ETH)
+	}
+	g.Step()
+	if len(g.items) != 2 {
+		t.Errorf("%d items after cheering between rounds, want 2", len(g.items))
+	}
+
+	g.Reset()
+	if cheers := g.CampCheers(); len(cheers) != 0 {
+		t.Errorf("cheers %v in the next round, want none", cheers)
+	}
+}
+
+func TestCheerHandler(t *testing.T) {
+	g := newTestGame()
+	pool := session.NewSessionPool()
+	s := pool.NewSession(nil, true)
+	r := &Room{game: g, app: &testApp{s: s}}
+	ctx := context.Background()
+
+	if _, err := r.Cheer(ctx, &CheerRequest{Camp: BTC}); err != nil {
+		t.Fatal(err)
+	}
+	// the cooldown is per session
+	if _, err := r.Cheer(ctx, &CheerRequest{Camp: BTC}); errorCode(err) != "RH-429" {
+		t.Errorf("second cheer within the cooldown: %v, want RH-429", err)
+	}
+	other := &Room{game: g, app: &testApp{s: pool.NewSession(nil, true)}}
+	if res, err := other.Cheer(ctx, &CheerRequest{Camp: BTC, Reaction: "🔥"}); err != nil || res.Cheers != 2 {
+		t.Errorf("cheer from another session: %+v, %v, want 2 cheers", res, err)
+	}
+	s.Set(lastCheerKey, time.Now().Add(-cheerCooldown).UnixMilli())
+	if res, err := r.Cheer(ctx, &CheerRequest{Camp: ETH}); err != nil || res.Cheers != 1 {
+		t.Errorf("cheer after the cooldown: %+v, %v, want 1 cheer", res, err)
+	}
+
+	s.Set(lastCheerKey, int64(0))
+	for _, req := range []CheerRequest{{Camp: Empty}, {Camp: MATIC + 1}, {Camp: BTC, Reaction: "a reaction far too long"}} {
+		if _, err := r.Cheer(ctx, &req); errorCode(err) != "RH-400" {
+			t.Errorf("cheer %+v: %v, want RH-400", req, err)
+		}
+	}
+	// a rejected cheer doesn't start the cooldown
+	if _, err := r.Cheer(ctx, &CheerRequest{Camp: ETH}); err != nil {
+		t.Errorf("cheer after rejected ones: %v", err)
+	}
+
+	mod := pool.NewSession(nil, true)
+	role.Set(mod, role.Moderator)
+	if _, err := (&Room{game: g, app: &testApp{s: mod}}).Cheer(ctx, &CheerRequest{Camp: BTC}); errorCode(err) != "RH-403" {
+		t.Errorf("moderator cheer: %v, want RH-403", err)
+	}
+
+	reactions, changed := r.cheers.flush()
+	if !changed || reactions[BTC][defaultReaction] != 1 || reactions[ETH][defaultReaction] != 2 {
+		t.Errorf("reactions %v", reactions)
+	}
+	if cheers := g.CampCheers(); cheers[BTC] != 2 || cheers[ETH] != 2 {
+		t.Errorf("cheers %v, want BTC 2 and ETH 2", cheers)
+	}
+}
@@ -0,0 +1,147 @@
+package game
+
+import (
+	"math"
+
+	"github.com/COAOX/zecrey_warrior/config"
+)
+
+// Balls collide with each other once they all moved: the pairs whose moves during
+// the tick brought them within reach are found with a spatial hash over the map,
+// then bounce as equal masses, see config.BallCollisionConfig.
+
+const (
+	clashBucketSize = 2 * cellPitch
+	clashColumns    = (mapColumn*cellPitch + clashBucketSize - 1) / clashBucketSize
+	clashRows       = (mapRow*cellPitch + clashBucketSize - 1) / clashBucketSize
+
+	// stealShare is the part of its speed the slower ball of a clash loses to the faster one in steal mode.
+	stealShare = 0.25
+)
+
+// clashBounds are the buckets covered by a ball during a tick.
+type clashBounds struct {
+	x0, y0, x1, y1 int
+}
+
+// clash resolves the collisions between the balls ids, in id order, that moved this tick.
+func (g *Game) clash(ids []uint64) {
+	collisions := g.Config().BallCollisions
+	mode := collisions.Mode
+	if mode == "" || mode == config.BallCollisionOff || len(ids) < 2 {
+		return
+	}
+	if g.buckets == nil {
+		g.buckets = make([][]*Player, clashColumns*clashRows)
+	}
+	for i := range g.buckets {
+		g.buckets[i] = g.buckets[i][:0]
+	}
+	for _, id := range ids {
+		p := g.players[id]
+		p.bounds = sweptBounds(p)
+		for by := p.bounds.y0; by <= p.bounds.y1; by++ {
+			for bx := p.bounds.x0; bx <= p.bounds.x1; bx++ {
+				g.buckets[by*clashColumns+bx] = append(g.buckets[by*clashColumns+bx], p)
+			}
+		}
+	}
+
+	for i, bucket := range g.buckets {
+		bx, by := i%clashColumns, i/clashColumns
+		for j, a := range bucket {
+			for _, b := range bucket[j+1:] {
+				// a pair sharing several buckets is resolved in the first one only
+				if maxInt(a.bounds.x0, b.bounds.x0) != bx || maxInt(a.bounds.y0, b.bounds.y0) != by {
+					continue
+				}
+				if collisions.OpposingOnly && a.Camp == b.Camp {
+					continue
+				}
+				g.collide(a, b, mode == config.BallCollisionSteal)
+			}
+		}
+	}
+}
+
+// sweptBounds returns the buckets covered by p between its start and end positions.
+func sweptBounds(p *Player) clashBounds {
+	d := float64(2 * p.R)
+	bucket := func(v float64, n int) int {
+		return clampInt(int(math.Floor((v-edgeWidth)/clashBucketSize)), 0, n-1)
+	}
+	return clashBounds{
+		x0: bucket(math.Min(p.startX, p.x), clashColumns),
+		y0: bucket(math.Min(p.startY, p.y), clashRows),
+		x1: bucket(math.Max(p.startX, p.x)+d, clashColumns),
+		y1: bucket(math.Max(p.startY, p.y)+d, clashRows),
+	}
+}
+
+// collide bounces a and b off each other if they met during the tick.
+func (g *Game) collide(a, b *Player, steal bool) {
+	reach := float64(a.R + b.R)
+	// b relative to a, the balls' centers are offset from their positions by their radius
+	ox, oy := b.startX-a.startX+float64(b.R-a.R), b.startY-a.startY+float64(b.R-a.R)
+	dx, dy := (b.x-b.startX)-(a.x-a.startX), (b.y-b.startY)-(a.y-a.startY)
+	t, ok := sweepCircle(ox, oy, dx, dy, 0, 0, reach)
+	if !ok {
+		return
+	}
+	nx, ny := ox+dx*t, oy+dy*t
+	l := math.Hypot(nx, ny)
+	if l == 0 {
+		return
+	}
+	nx, ny = nx/l, ny/l
+
+	// equal masses exchange their velocities along the normal
+	vn := (b.Vx-a.Vx)*nx + (b.Vy-a.Vy)*ny
+	if vn >= 0 {
+		return
+	}
+	speedA, speedB := math.Hypot(a.Vx, a.Vy), math.Hypot(b.Vx, b.Vy)
+	a.Vx, a.Vy = a.Vx+vn*nx, a.Vy+vn*ny
+	b.Vx, b.Vy = b.Vx-vn*nx, b.Vy-vn*ny
+	if steal && speedA != speedB {
+		if speedA > speedB {
+			share := stealShare * speedB
+			setSpeed(a, speedA+share, -nx, -ny)
+			setSpeed(b, speedB-share, nx, ny)
+		} else {
+			share := stealShare * speedA
+			setSpeed(a, speedA-share, -nx, -ny)
+			setSpeed(b, speedB+share, nx, ny)
+		}
+	}
+
+	// push overlapping balls apart, within the map
+	cx, cy := b.x-a.x+float64(b.R-a.R), b.y-a.y+float64(b.R-a.R)
+	if d := math.Hypot(cx, cy); d < reach {
+		push := (reach - d) / 2
+		a.x, a.y = g.clampToMap(a, a.x-push*nx, a.y-push*ny)
+		b.x, b.y = g.clampToMap(b, b.x+push*nx, b.y+push*ny)
+	}
+}
+
+// setSpeed scales the velocity of p to speed, pointing it along fx, fy if it is 0.
+func setSpeed(p *Player, speed, fx, fy float64) {
+	if s := math.Hypot(p.Vx, p.Vy); s > 0 {
+		p.Vx, p.Vy = p.Vx/s*speed, p.Vy/s*speed
+		return
+	}
+	p.Vx, p.Vy = fx*speed, fy*speed
+}
+
+// clampToMap returns the position x, y of p moved inside the edges of the map.
+func (g *Game) clampToMap(p *Player, x, y float64) (float64, float64) {
+	d := float64(2 * p.R)
+	return clamp(x, edgeWidth, edgeWidth+g.Map.W()-d), clamp(y, edgeWidth, edgeWidth+g.Map.H()-d)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
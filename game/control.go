@@ -0,0 +1,261 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const controlTimeout = 30 * time.Second
+
+// states of a control call
+const (
+	controlQueued int32 = iota
+	controlRunning
+	controlAbandoned // its caller gave up, it must not run
+)
+
+var (
+	ErrControlTimeout = errors.New("game loop busy, control timed out")
+	ErrNotRunning     = errors.New("round is not running")
+	ErrNotPaused      = errors.New("round is not paused")
+	ErrPlayerNotFound = errors.New("player not found")
+	ErrInvalidCell    = errors.New("cell index out of map")
+	ErrInvalidItem    = errors.New("unknown item type")
+)
+
+// RoundStatus describes the running round for operators.
+type RoundStatus struct {
+	GameID       uint       `json:"game_id"`
+	Status       GameStatus `json:"status"`
+	Remaining    int64      `json:"remaining"` // seconds
+	NextDuration int        `json:"next_duration"`
+	Players      int        `json:"players"`
+	Items        int        `json:"items"`
+}
+
+// submit queues f to run on the game loop before the next frame, without waiting.
+// It reports false, and drops f, if the queue is full.
+func (g *Game) submit(f func()) bool {
+	select {
+	case g.commands <- f:
+		return true
+	default:
+		g.log().Warn("game command queue full, dropping command")
+		return false
+	}
+}
+
+// control runs f on the game loop between two frames and returns its error.
+func (g *Game) control(f func() error) error {
+	return g.controlCtx(context.Background(), f)
+}
+
+// controlCtx is control that also gives up when ctx is done, for callers with their own deadline.
+// f doesn't run once its caller gave up.
+func (g *Game) controlCtx(ctx context.Context, f func() error) error {
+	state := controlQueued
+	errChan := make(chan error, 1)
+	run := func() {
+		if atomic.CompareAndSwapInt32(&state, controlQueued, controlRunning) {
+			errChan <- f()
+		}
+	}
+	abandon := func(err error) error {
+		if atomic.CompareAndSwapInt32(&state, controlQueued, controlAbandoned) {
+			return err
+		}
+		// too late, it is running
+		return <-errChan
+	}
+
+	timeout := time.NewTimer(controlTimeout)
+	defer timeout.Stop()
+	select {
+	case g.controls <- run:
+	case <-timeout.C:
+		return ErrControlTimeout
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-g.ctx.Done():
+		return g.ctx.Err()
+	}
+	select {
+	case err := <-errChan:
+		return err
+	case <-timeout.C:
+		return abandon(ErrControlTimeout)
+	case <-ctx.Done():
+		return abandon(ctx.Err())
+	case <-g.ctx.Done():
+		return abandon(g.ctx.Err())
+	}
+}
+
+func (g *Game) startRoundTimer(d time.Duration) {
+	if g.roundTimer == nil {
+		g.roundTimer = time.NewTimer(d)
+	} else {
+		g.roundTimer.Stop()
+		select {
+		case <-g.roundTimer.C:
+		default:
+		}
+		g.roundTimer.Reset(d)
+	}
+	g.roundDeadline = time.Now().Add(d)
+}
+
+// Pause freezes the balls and the round timer.
+func (g *Game) Pause() error {
+	return g.control(func() error {
+		if g.GameStatus != GameRunning {
+			return ErrNotRunning
+		}
+		g.roundTimer.Stop()
+		g.roundRemain = time.Until(g.roundDeadline)
+		g.GameStatus = GamePaused
+		g.log().Info("round paused", zap.Duration("remaining", g.roundRemain))
+		return nil
+	})
+}
+
+// Resume continues a paused round with the time it had left.
+func (g *Game) Resume() error {
+	return g.control(func() error {
+		if g.GameStatus != GamePaused {
+			return ErrNotPaused
+		}
+		g.startRoundTimer(g.roundRemain)
+		g.dbGameMu.Lock()
+		g.dbGame.EndTime = g.roundDeadline
+		g.dbGameMu.Unlock()
+		g.GameStatus = GameRunning
+		g.log().Info("round resumed", zap.Duration("remaining", g.roundRemain))
+		return nil
+	})
+}
+
+// EndRound ends the round now, settling it as if its time was up.
+func (g *Game) EndRound() error {
+	return g.control(func() error {
+		if g.GameStatus != GameRunning && g.GameStatus != GamePaused {
+			return ErrNotRunning
+		}
+		g.GameStatus = GameRunning
+		g.endRound()
+		return nil
+	})
+}
+
+// RestartRound abandons the round without a winner and starts a fresh one.
+func (g *Game) RestartRound() error {
+	return g.control(func() error {
+		if g.GameStatus != GameRunning && g.GameStatus != GamePaused {
+			return ErrNotRunning
+		}
+		g.dbGameMu.Lock()
+		g.dbGame.EndTime = time.Now()
+		g.dbGameMu.Unlock()
+		g.updateGame()
+		g.dropCheckpoint()
+		close(g.roundEnd)
+		g.log().Info("round restarted")
+		g.Reset()
+		g.startRoundTimer(time.Duration(g.Config().GameDuration) * time.Second)
+		g.onGameStart(g.ctx)
+		return nil
+	})
+}
+
+// SpawnItem drops an item on cell x, y, or on a random cell when x or y is negative.
+func (g *Game) SpawnItem(itemType ItemType, x, y int) (*ItemObject, error) {
+	if _, ok := ItemMap[itemType]; !ok {
+		return nil, ErrInvalidItem
+	}
+	if x >= mapColumn || y >= mapRow {
+		return nil, ErrInvalidCell
+	}
+	var item *ItemObject
+	err := g.control(func() error {
+		// the next round starts on a fresh map
+		if g.GameStatus == GameStopped {
+			return ErrNotRunning
+		}
+		sx, sy := g.Map.RandomSpaceXY(g.rnd)
+		if x >= 0 && y >= 0 {
+			sx, sy = cellIndexToSpaceXY(x, y)
+		}
+		item = g.addItem(sx, sy, itemType)
+		return nil
+	})
+	return item, err
+}
+
+// RemovePlayer takes the player's ball out of the arena.
+func (g *Game) RemovePlayer(playerID uint64) error {
+	return g.control(func() error {
+		if _, ok := g.players[playerID]; !ok {
+			return ErrPlayerNotFound
+		}
+		delete(g.players, playerID)
+		return nil
+	})
+}
+
+// Checkpoint checkpoints the round on the game loop, or gives up when ctx is done.
+func (g *Game) Checkpoint(ctx context.Context) error {
+	return g.controlCtx(ctx, func() error {
+		g.checkpoint()
+		return nil
+	})
+}
+
+// WaitRoundEnd blocks until the current round is settled or abandoned, or ctx is done.
+// It returns right away between rounds, and with ErrNotRunning while the round is
+// paused: it doesn't end before it is resumed.
+func (g *Game) WaitRoundEnd(ctx context.Context) error {
+	if status := g.Snapshot().Status; status != GameRunning {
+		return roundEndErr(status)
+	}
+	var end <-chan struct{}
+	if err := g.controlCtx(ctx, func() error {
+		end = g.roundEnd
+		return roundEndErr(g.GameStatus)
+	}); err != nil {
+		return err
+	}
+	select {
+	case <-end:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func roundEndErr(status GameStatus) error {
+	if status == GamePaused {
+		return ErrNotRunning
+	}
+	return nil
+}
+
+// Status reports the state of the current round.
+func (g *Game) Status() (RoundStatus, error) {
+	var status RoundStatus
+	err := g.control(func() error {
+		status = RoundStatus{
+			GameID:       g.GetGameID(),
+			Status:       g.GameStatus,
+			Remaining:    int64(g.remaining().Seconds()),
+			NextDuration: g.nextRoundDuration(),
+			Players:      len(g.players),
+			Items:        len(g.items),
+		}
+		return nil
+	})
+	return status, err
+}
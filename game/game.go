@@ -1,88 +1,134 @@
 package game
 
 import (
-	"bytes"
 	"context"
-	"encoding/binary"
-	"fmt"
-	"strconv"
-	"strings"
+	"math/rand"
+	"sort"
 	"sync"
 	"sync/atomic"
 	"time"
 
 	"github.com/COAOX/zecrey_warrior/config"
 	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/logging"
+	"github.com/COAOX/zecrey_warrior/metrics"
 	"github.com/COAOX/zecrey_warrior/model"
-	"github.com/kvartborg/vector"
-	"github.com/solarlune/resolv"
 	"go.uber.org/zap"
 )
 
 type GameStatus int
 
 const (
-	CellTag           = "CELL"
-	EdgeTag           = "EDGE"
-	HorizontalEdgeTag = "HORIZONTAL"
-	VerticalEdgeTag   = "VERTICAL"
-
 	minCellSize = 5
 	edgeWidth   = minCellSize + lineWidth
 
 	playerInitialVelocity = 1
 
-	GameNotStarted GameStatus = iota
+	// maxCollisionSteps bounds the collisions resolved for a ball in one frame, a ball
+	// wedged between objects would otherwise never use up its move and hang the loop.
+	maxCollisionSteps = 16
+
+	// commandQueueSize bounds the commands waiting for the next frame.
+	commandQueueSize = 4096
+	// controlQueueSize bounds the control calls waiting for the loop, their callers wait too.
+	controlQueueSize = 64
+)
+
+// The statuses are sent to the clients and shown by the admin API, their values
+// must not change.
+const (
+	GameNotStarted GameStatus = iota + 7
 	GameRunning
 	GameStopped
+	GamePaused
 )
 
 type Game struct {
 	db                *db.Client
-	cfg               *config.Config
+	writer            *db.Writer
+	cfg               atomic.Value // *config.Config, replaced as a whole at round boundaries, never modified
 	onGameStart       func(context.Context)
 	onGameStop        func(context.Context)
 	onCampVotesChange func(camp Camp, votes int32)
 
-	res *res
+	res     *res
+	settler *settler
+	rnd     *rand.Rand
+	ranks   rankCache
 
-	space       *resolv.Space
+	// the state below is owned by the game loop, other goroutines submit
+	// commands to change it and read the published Snapshot
 	frameNumber uint32
-	campVotes   sync.Map
+	itemSeq     uint32
+	campVotes   map[Camp]int32
+	campCheers  [MATIC + 1]int32 // updated atomically, cheers don't go through the loop
+	players     map[uint64]*Player
+	names       sync.Map // player id -> name, of the balls in the round, for the casters
+	items       map[uint32]*ItemObject
+	published   atomic.Value // *Snapshot
+	buckets     [][]*Player  // spatial hash of the balls, reused by clash
 
 	dbGame     *model.Game
+	dbGameMu   sync.RWMutex // guards dbGame.ID, which the writer sets if the game is created late
 	ctx        context.Context
 	Map        Map `json:"map"`
 	GameStatus GameStatus
 
-	Players sync.Map `json:"players"`
-	Items   sync.Map `json:"items"`
-
 	nextRoundChan  chan struct{}
 	stopSignalChan chan chan struct{}
+	commands       chan func()
+	controls       chan func()   // run between rounds too, unlike commands
+	roundEnd       chan struct{} // closed when the round is settled or abandoned
+
+	// a reloaded config and an admin round duration wait for the next round,
+	// the admin duration wins until the file's game_duration changes
+	pendingCfg       *config.Config
+	fileDuration     int // game_duration of the last config read from the file
+	durationOverride int // seconds set by SetNextRoundDuration, 0 if none
+	pendingCfgMu     sync.Mutex
+
+	tickRate      int     // fixed for the life of the game, unlike cfg.TickRate
+	velocityScale float64 // tunedTickRate / tickRate
+	ticks         tickStats
+
+	roundTimer    *time.Timer
+	roundDeadline time.Time
+	roundRemain   time.Duration // remaining round time while paused, or of a resumed round before it starts
 }
 
-func NewGame(ctx context.Context, cfg *config.Config, db *db.Client, onGameStart func(context.Context), onGameStop func(context.Context), onCampVotesChange func(camp Camp, votes int32)) *Game {
+func NewGame(ctx context.Context, cfg *config.Config, db *db.Client, writer *db.Writer, onGameStart func(context.Context), onGameStop func(context.Context), onCampVotesChange func(camp Camp, votes int32)) *Game {
 	v := &Game{
 		ctx:               ctx,
 		db:                db,
-		cfg:               cfg,
-		campVotes:         sync.Map{},
-		Players:           sync.Map{},
-		Items:             sync.Map{},
+		writer:            writer,
+		campVotes:         map[Camp]int32{},
+		players:           map[uint64]*Player{},
+		items:             map[uint32]*ItemObject{},
 		onGameStart:       onGameStart,
 		onGameStop:        onGameStop,
 		onCampVotesChange: onCampVotesChange,
 		GameStatus:        GameNotStarted,
 		stopSignalChan:    make(chan chan struct{}, 1),
 		nextRoundChan:     make(chan struct{}, 1),
+		commands:          make(chan func(), commandQueueSize),
+		controls:          make(chan func(), controlQueueSize),
+		roundEnd:          make(chan struct{}),
+		settler:           newSettler(db, writer),
+		rnd:               newRand(time.Now().UnixNano()),
+		tickRate:          cfg.SimulationRate(),
+		fileDuration:      cfg.GameDuration,
 	}
+	v.cfg.Store(cfg)
+	v.velocityScale = float64(tunedTickRate) / float64(v.tickRate)
 
-	zap.L().Debug("game init")
+	roomLog().Debug("game init")
 
-	v.initMap()
-	v.initGameInfo()
+	if !v.resume() {
+		v.initMap()
+		v.initGameInfo()
+	}
 	v.resetRes()
+	v.publish()
 
 	// v.AddPlayer(11111, BTC)
 	// v.AddPlayer(22222, ETH)
@@ -93,70 +139,142 @@ func NewGame(ctx context.Context, cfg *config.Config, db *db.Client, onGameStart
 	return v
 }
 
-func (g *Game) initMap() {
-	g.Map = NewMap()
+// Seed reseeds the random draws of the game, spawn angles and items, to replay a round.
+func (g *Game) Seed(seed int64) {
+	g.rnd.Seed(seed)
+}
 
-	g.space = resolv.NewSpace(int(g.Map.W())+2*edgeWidth, int(g.Map.H())+2*edgeWidth, edgeWidth, edgeWidth)
-	g.space.Add(resolv.NewObject(0, 0, g.Map.W()+edgeWidth, edgeWidth, EdgeTag, HorizontalEdgeTag))
-	g.space.Add(resolv.NewObject(0, edgeWidth, edgeWidth, g.Map.W()+edgeWidth, EdgeTag, VerticalEdgeTag))
-	g.space.Add(resolv.NewObject(g.Map.W()+edgeWidth, 0, edgeWidth, g.Map.H()+edgeWidth, EdgeTag, VerticalEdgeTag))
-	g.space.Add(resolv.NewObject(edgeWidth, g.Map.H()+edgeWidth, g.Map.W()+edgeWidth, edgeWidth, EdgeTag, HorizontalEdgeTag))
+func (g *Game) initMap() {
+	g.initMapWith(nil)
+}
 
+// initMapWith builds the map with the given cells or, if nil, the initial camps.
+func (g *Game) initMapWith(cells []Camp) {
+	g.Map = NewMap()
 	for y := 0; y < mapRow; y++ {
 		for x := 0; x < mapColumn; x++ {
 			camp := initCamp(x, y)
-			ox, oy := cellIndexToSpaceXY(x, y)
-			g.space.Add(resolv.NewObject(ox, oy, float64(cellWidth), float64(cellHeight), CampTagMap[camp], CellTag, CellIndexToTag(x, y)))
+			if cells != nil {
+				camp = cells[y*mapColumn+x]
+			}
 			g.Map.Cells = append(g.Map.Cells, camp)
 		}
 	}
 }
 
 func (g *Game) initGameInfo() {
-	g.dbGame = &model.Game{StartTime: time.Now(), EndTime: time.Now().Add(time.Duration(g.cfg.GameDuration) * time.Second)}
-	if err := g.db.Game.Create(g.dbGame); err != nil {
-		zap.L().Error("failed to create game", zap.Error(err))
+	gm := &model.Game{StartTime: time.Now(), EndTime: time.Now().Add(time.Duration(g.Config().GameDuration) * time.Second)}
+	create := func(c *db.Client) error {
+		g.dbGameMu.Lock()
+		defer g.dbGameMu.Unlock()
+		return c.Game.Create(gm)
+	}
+	// the round runs anyway, the game row is created once the database is back;
+	// while it is known to be down, the loop doesn't wait on it
+	if g.db.Degraded() {
+		g.writer.Do(create)
+	} else if err := g.db.Game.Create(gm); err != nil {
+		roomLog().Error("failed to create game, queued for replay", zap.Error(err))
+		g.writer.Do(create)
 	}
-	zap.L().Debug("game info init", zap.Uint("game_id", g.dbGame.ID))
+	g.dbGameMu.Lock()
+	g.dbGame = gm
+	g.dbGameMu.Unlock()
+	g.log().Debug("game info init")
 }
 
 func (g *Game) resetRes() {
 	g.res = nil
 }
 
+// GetGameID returns the id of the current game, 0 while its row is not created.
 func (g *Game) GetGameID() uint {
+	g.dbGameMu.RLock()
+	defer g.dbGameMu.RUnlock()
 	return g.dbGame.ID
 }
 
-func (g *Game) start() <-chan []byte {
-	g.GameStatus = GameRunning
-	stateChan := make(chan []byte)
-	go func() {
-		gameTime := time.NewTimer(time.Duration(g.cfg.GameDuration) * time.Second)
-		for {
-			s, _ := g.Serialize()
-			g.Update()
-			select {
-			case <-g.ctx.Done():
-				return
-			case <-gameTime.C:
-				g.nextRound()
-				gameTime.Reset(time.Duration(g.cfg.GameDuration) * time.Second)
-			default:
-				stateChan <- s
+// log returns the logger of the game room, with the id of the current round.
+func (g *Game) log() *zap.Logger {
+	return roundLog(g.GetGameID())
+}
+
+// roomLog returns the logger of the game room.
+func roomLog() *zap.Logger {
+	return zap.L().With(logging.Room(config.GameRoomName))
+}
+
+// roundLog returns the logger of the game room for the round gameID.
+func roundLog(gameID uint) *zap.Logger {
+	return roomLog().With(logging.GameID(gameID))
+}
+
+// GameRef resolves, when called, the id of the current game row.
+func (g *Game) GameRef() db.GameRef {
+	g.dbGameMu.RLock()
+	gm := g.dbGame
+	g.dbGameMu.RUnlock()
+	return func() uint {
+		g.dbGameMu.RLock()
+		defer g.dbGameMu.RUnlock()
+		return gm.ID
+	}
+}
+
+func (g *Game) updateGame() {
+	g.dbGameMu.RLock()
+	defer g.dbGameMu.RUnlock()
+	if g.db.Degraded() {
+		// replayed after the row is created, if that is queued too
+		gm := g.dbGame
+		g.writer.Do(func(c *db.Client) error {
+			g.dbGameMu.RLock()
+			update := *gm
+			g.dbGameMu.RUnlock()
+			if update.ID == 0 {
+				return nil
 			}
-		}
-	}()
-	return stateChan
+			return c.Game.Update(&update)
+		})
+		return
+	}
+	if g.dbGame.ID == 0 {
+		return
+	}
+	if err := g.db.Game.Update(g.dbGame); err != nil {
+		roundLog(g.dbGame.ID).Error("failed to update game", zap.Error(err))
+	}
 }
 
-func (g *Game) nextRound() {
+// Start runs the game loop, at cfg.TickRate ticks per second, until the game's context is done.
+// The game room starts it once registered, a game used without a room must be started.
+func (g *Game) Start() {
+	g.GameStatus = GameRunning
+	go g.settler.run(g.ctx, g.GetGameID)
+	d := time.Duration(g.Config().GameDuration) * time.Second
+	if g.roundRemain > 0 {
+		d, g.roundRemain = g.roundRemain, 0
+	}
+	g.startRoundTimer(d)
+	go g.run()
+}
+
+// endRound settles the round and starts the break before the next one, see startRound.
+// It runs on the game loop.
+func (g *Game) endRound() {
 	g.Save()
 	g.GameStatus = GameStopped
+	// the loop doesn't tick until the next round, readers must not take it for stuck
+	g.publish()
 	g.stopSignalChan <- g.nextRoundChan
+	close(g.roundEnd)
 	g.onGameStop(g.ctx)
-	// wait game to start
-	<-time.After(time.Duration(g.cfg.GameRoundInterval) * time.Second)
+	g.startRoundTimer(time.Duration(g.Config().GameRoundInterval) * time.Second)
+}
+
+// startRound starts the next round once the break is over, it runs on the game loop.
+func (g *Game) startRound() {
+	g.applyPendingConfig()
 	g.Reset()
 
 	// g.AddPlayer(11111, BTC)
@@ -165,73 +283,46 @@ func (g *Game) nextRound() {
 	// g.AddPlayer(44444, AVAX)
 	// g.AddPlayer(55555, MATIC)
 
+	g.startRoundTimer(time.Duration(g.Config().GameDuration) * time.Second)
 	g.onGameStart(g.ctx)
 	g.nextRoundChan <- struct{}{}
 }
 
-// frame number: 4 bytes
-// map size: 4 bytes
-// map: map size bytes
-// player number: 4 bytes
-// players: 26 * len(players) bytes
-// item number: 4 bytes
-// items: 21 * items number bytes
-func (g *Game) Serialize() ([]byte, error) {
-	atomic.AddUint32(&g.frameNumber, 1)
-	bytesBuf := bytes.NewBuffer([]byte{})
-	b := make([]byte, 4)
-	binary.BigEndian.PutUint32(b, g.frameNumber)
-	_, err := bytesBuf.Write(b)
-	if err != nil {
-		return bytesBuf.Bytes(), err
+// Step applies the queued commands and control calls, advances the game by one frame and
+// publishes the new state. Only the game loop, or a caller driving the game without one, may call it.
+func (g *Game) Step() {
+	for n := len(g.commands); n > 0; n-- {
+		(<-g.commands)()
 	}
+	for n := len(g.controls); n > 0; n-- {
+		(<-g.controls)()
+	}
+	g.Update()
+	g.frameNumber++
+	g.publish()
+}
 
-	binary.BigEndian.PutUint32(b, g.Map.Size())
-	bytesBuf.Write(b)
-	bytesBuf.Write(g.Map.Serialize())
-
-	playerNumber := uint32(0)
-	playerBytes := []byte{}
-	g.Players.Range(func(key, value interface{}) bool { // O(N) call, but since players are not that many, it's fine
-		if v, ok := value.(*Player); ok && v != nil {
-			playerNumber++
-			playerBytes = append(playerBytes, v.Serialize()...)
-		}
-		return true
-	})
-	binary.BigEndian.PutUint32(b, playerNumber)
-	bytesBuf.Write(b)
-	bytesBuf.Write(playerBytes)
-
-	itemNumber := uint32(0)
-	itemBytes := []byte{}
-	g.Items.Range(func(key, value interface{}) bool { // O(N) call, but since items are not that many, it's fine
-		if v, ok := value.(*ItemObject); ok && v != nil {
-			itemNumber++
-			itemBytes = append(itemBytes, v.Serialize()...)
-		}
-		return true
-	})
-	binary.BigEndian.PutUint32(b, itemNumber)
-	bytesBuf.Write(b)
-	bytesBuf.Write(itemBytes)
-	return bytesBuf.Bytes(), nil
+// Serialize encodes the state published after the last frame, see Snapshot.Serialize.
+// It may be called from any goroutine.
+func (g *Game) Serialize() ([]byte, error) {
+	return g.Snapshot().Serialize(), nil
 }
 
+// Save settles the round: the winner is recorded first, so that the reconciler
+// can settle the round if the settlement transaction fails.
 func (g *Game) Save() {
 	winner, _ := g.GetWinner()
-	campID := uint8(winner)
-	g.dbGame.WinnerID = campID
+	g.dbGameMu.Lock()
+	g.dbGame.WinnerID = uint8(winner)
 	g.dbGame.EndTime = time.Now()
-	if err := g.db.Game.Update(g.dbGame); err != nil {
-		zap.L().Error("failed to update game", zap.Error(err))
-	}
-	if err := g.db.Camp.IncreaseScore(campID); err != nil {
-		zap.L().Error("failed to increase camp score", zap.Error(err))
-	}
-	if err := g.db.Player.IncreaseScore(g.dbGame.ID, campID); err != nil {
-		zap.L().Error("failed to increase player score", zap.Error(err))
-	}
+	endTime := g.dbGame.EndTime
+	g.dbGameMu.Unlock()
+	g.updateGame()
+	g.dropCheckpoint()
+	settled := g.settler.settle(settlement{game: g.GameRef(), winner: winner, endTime: endTime})
+	g.dbGameMu.Lock()
+	g.dbGame.Settled = settled
+	g.dbGameMu.Unlock()
 }
 
 func (g *Game) GetWinner() (Camp, int) {
@@ -254,156 +345,120 @@ func (g *Game) GetWinner() (Camp, int) {
 	return winner, maxScore
 }
 
+// Config returns the config of the current round. It is shared: a reloaded config
+// replaces it at the next round boundary, it is never modified.
+func (g *Game) Config() *config.Config {
+	return g.cfg.Load().(*config.Config)
+}
+
+// Reload schedules cfg to take effect at the next round boundary.
+func (g *Game) Reload(cfg *config.Config) {
+	g.pendingCfgMu.Lock()
+	defer g.pendingCfgMu.Unlock()
+	g.pendingCfg = cfg
+	if cfg.GameDuration != g.fileDuration {
+		g.fileDuration, g.durationOverride = cfg.GameDuration, 0
+	}
+}
+
+// SetNextRoundDuration changes the duration, in seconds, of the rounds after the current one.
+// It holds over reloads of the config file, until its game_duration changes.
+func (g *Game) SetNextRoundDuration(seconds int) error {
+	g.pendingCfgMu.Lock()
+	defer g.pendingCfgMu.Unlock()
+	g.durationOverride = seconds
+	return nil
+}
+
+// nextRoundDuration returns the duration, in seconds, of the next round.
+func (g *Game) nextRoundDuration() int {
+	g.pendingCfgMu.Lock()
+	defer g.pendingCfgMu.Unlock()
+	switch {
+	case g.durationOverride > 0:
+		return g.durationOverride
+	case g.pendingCfg != nil:
+		return g.pendingCfg.GameDuration
+	}
+	return g.Config().GameDuration
+}
+
+func (g *Game) applyPendingConfig() {
+	g.pendingCfgMu.Lock()
+	defer g.pendingCfgMu.Unlock()
+	cfg := g.Config()
+	if g.pendingCfg != nil {
+		cfg = cfg.WithSafe(g.pendingCfg)
+	}
+	if g.durationOverride > 0 && g.durationOverride != cfg.GameDuration {
+		c := *cfg
+		c.GameDuration = g.durationOverride
+		cfg = &c
+	}
+	if cfg == g.Config() {
+		return
+	}
+	g.cfg.Store(cfg)
+	g.pendingCfg = nil
+	g.log().Info("config applied", zap.Int("fps", cfg.FPS), zap.Int("game_duration", cfg.GameDuration), zap.Int("item_frame_chance", cfg.ItemFrameChance))
+}
+
 func (g *Game) Reset() {
-	g.Players = sync.Map{}
-	g.campVotes = sync.Map{}
-	g.Items = sync.Map{}
+	g.players = map[uint64]*Player{}
+	g.names.Range(func(key, value interface{}) bool {
+		g.names.Delete(key)
+		return true
+	})
+	g.items = map[uint32]*ItemObject{}
+	g.campVotes = map[Camp]int32{}
+	for i := range g.campCheers {
+		atomic.StoreInt32(&g.campCheers[i], 0)
+	}
 	g.frameNumber = 0
+	g.roundEnd = make(chan struct{})
 	g.initMap()
 	g.initGameInfo()
 	g.resetRes()
 	g.GameStatus = GameRunning
+	g.publish()
 }
 
 func (g *Game) Update() {
 	if g.GameStatus != GameRunning {
 		return
 	}
-	g.Players.Range(func(key, value interface{}) bool {
-		if player, ok := value.(*Player); ok && player != nil && player.playerObj != nil {
-			remainX, remainY := player.Vx, player.Vy
-
-			change := false
-			for remainX != 0 || remainY != 0 {
-				dx, dy := remainX, remainY
-				if collision := player.playerObj.Check(dx, dy, getCollisionTags(player.Camp)...); collision != nil {
-					collisionObj := collision.Objects[0]
-					dx, dy = resolvDxDy(dx, dy, collision.ContactWithObject(collisionObj))
-					if collisionObj.HasTags(CellTag) {
-						remainX, remainY = player.rebound(dx, dy, remainX, remainY, collisionObj)
-						if !change {
-							change = true
-							x, y := GetCellIndex(collisionObj.Tags())
-							g.Map.Cells[y*mapColumn+x] = player.Camp
-							collisionObj.RemoveTags(removeCampTags(collisionObj.Tags())...)
-							collisionObj.AddTags(CampTagMap[player.Camp])
-						}
-					} else if collisionObj.HasTags(EdgeTag) {
-						if collisionObj.HasTags(HorizontalEdgeTag) {
-							player.Vy = -player.Vy
-							remainX -= dx
-							remainY = dy - remainY
-						} else {
-							player.Vx = -player.Vx
-							remainX = dx - remainX
-							remainY -= dy
-						}
-					} else if collisionObj.HasTags(ItemTag) {
-						if collisionObj.HasTags(AcceleratorTag) {
-							player.Vx *= 1.5
-							player.Vy *= 1.5
-							remainX *= 1.5
-							remainY *= 1.5
-							id := itemTagsToId(collisionObj.Tags())
-							g.Items.Delete(id)
-							g.space.Remove(collisionObj)
-						}
-					}
-				} else {
-					remainX -= dx
-					remainY -= dy
-				}
-				// fmt.Println("#inner camp:", CampTagMap[player.Camp], "x:", player.playerObj.X, "y:", player.playerObj.Y, "dx:", dx, "dy:", dy, "vx:", player.Vx, "vy:", player.Vy, "rx:", remainX, "ry:", remainY)
-				player.playerObj.X += dx
-				player.playerObj.Y += dy
-				player.playerObj.Update()
-			}
-			g.Players.Store(key, player)
-		}
-		return true
-	})
+	// balls move in id order, so that a tick only depends on the game state
+	ids := make([]uint64, 0, len(g.players))
+	for id := range g.players {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	for _, id := range ids {
+		p := g.players[id]
+		p.startX, p.startY = p.x, p.y
+		g.move(p)
+	}
+	g.clash(ids)
 	g.TryAddItem()
 }
 
 func (g *Game) Size() uint32 {
 	pLen := uint32(0)
-	g.Players.Range(func(key, value interface{}) bool { // O(N) call, but since players are not that many, it's fine
-		if v, ok := value.(*Player); ok && v != nil {
-			pLen += v.Size()
-		}
-		return true
-	})
+	for _, p := range g.players {
+		pLen += p.Size()
+	}
 	return 4 + 4 + g.Map.Size() + pLen
 }
 
 func (g *Game) incrCampVotes(camp Camp) {
-	votes := int32(0)
-	v, _ := g.campVotes.LoadOrStore(camp, &votes)
-	i, ok := v.(*int32)
-	if !ok {
-		g.campVotes.Store(camp, &votes)
-	}
-	n := atomic.AddInt32(i, 1)
-	g.onCampVotesChange(camp, n)
-}
-
-func GetCellIndex(tags []string) (int, int) {
-	for _, tag := range tags {
-		s := strings.Split(tag, ",")
-		if len(s) == 2 {
-			x, _ := strconv.Atoi(s[0])
-			y, _ := strconv.Atoi(s[1])
-			return x, y
-		}
-	}
-	return 0, 0
+	g.campVotes[camp]++
+	metrics.Votes.WithLabelValues(CampTagMap[camp]).Inc()
+	g.onCampVotesChange(camp, g.campVotes[camp])
 }
 
-func CellIndexToTag(x, y int) string {
-	return fmt.Sprintf("%d,%d", x, y)
-}
-
-func CellTagToIndex(tag string) (int, int) {
-	s := strings.Split(tag, ",")
-	y, _ := strconv.Atoi(s[0])
-	x, _ := strconv.Atoi(s[1])
-	return x, y
-}
-
-func resolvDxDy(dx, dy float64, cvector vector.Vector) (x float64, y float64) {
-	x, y = dx, dy
-	cx, cy := cvector.X(), cvector.Y()
-	xDistance, yDistance := float64(1), float64(1)
-	if (cx < 0 && dx < cx) || (cx > 0 && dx > cx) {
-		xDistance = cx / dx
-	}
-	if cx == 0 {
-		if x == 0 {
-			xDistance = 1
-		} else {
-			xDistance = 0
-		}
-	}
-
-	if (cy < 0 && dy < cy) || (cy > 0 && dy > cy) {
-		yDistance = cy / dy
-	}
-	if cy == 0 {
-		if y == 0 {
-			yDistance = 1
-		} else {
-			yDistance = 0
-		}
-	}
-
-	if xDistance < yDistance {
-		y *= xDistance
-		x *= xDistance
-	} else {
-		x *= yDistance
-		y *= yDistance
-	}
-	return
+// CampVotes returns the votes of every camp in this round, as of the last frame.
+func (g *Game) CampVotes() map[Camp]int32 {
+	return g.Snapshot().CampVotes
 }
 
 func map2SpaceXY(x, y float64) (float64, float64) {
@@ -1,45 +1,55 @@
 package game
 
-import "github.com/COAOX/zecrey_warrior/model"
+import (
+	"sync"
+
+	"github.com/COAOX/zecrey_warrior/model"
+	"go.uber.org/zap"
+)
 
 type GameInfo struct {
 	*model.Game
 	GameRound      uint            `json:"game_round"`
 	HistoryMessage []model.Message `json:"history_message"`
 	CampVotes      map[Camp]int32  `json:"camp_votes"`
+	CampCheers     map[Camp]int32  `json:"camp_cheers"`
 	CampRank       []model.Camp    `json:"camp_rank"`
 	PlayerRank     []model.Player  `json:"player_rank"`
+	Degraded       bool            `json:"degraded"`
+}
+
+// rankCache keeps the last lists read from the database, they are served
+// instead while the database is unavailable.
+type rankCache struct {
+	mu             sync.RWMutex
+	historyMessage []model.Message
+	campRank       []model.Camp
+	playerRank     []model.Player
 }
 
+// GetGameInfo never fails on database errors, it falls back to the last known
+// lists and flags the info as degraded.
 func (g *Game) GetGameInfo() (GameInfo, error) {
-	var err error
+	g.dbGameMu.RLock()
+	gm := *g.dbGame
+	g.dbGameMu.RUnlock()
 	v := GameInfo{
-		Game:      g.dbGame,
-		GameRound: g.dbGame.ID,
-		CampVotes: map[Camp]int32{},
-	}
-	offset, limit := 0, 100
-	v.HistoryMessage, err = g.db.Message.ListLatest(offset, limit)
-	if err != nil {
-		return v, err
-	}
-	g.campVotes.Range(func(key, value interface{}) bool {
-		if c, ok := key.(Camp); ok && value.(*int32) != nil {
-			v.CampVotes[c] = *(value.(*int32))
-		}
-		return true
-	})
+		Game:      &gm,
+		GameRound: gm.ID,
+		CampVotes: g.CampVotes(),
 
-	rankLimit := 3
-	v.CampRank, err = g.db.Camp.ListRank(rankLimit)
-	if err != nil {
-		return v, err
+		CampCheers: g.CampCheers(),
 	}
 
-	v.PlayerRank, err = g.db.Player.ListRank(rankLimit)
-	if err != nil {
-		return v, err
+	offset, limit := 0, 100
+	var history []model.Message
+	err := errDegraded
+	if !g.db.Degraded() {
+		history, err = g.db.Message.ListLatest(offset, limit)
 	}
+	v.HistoryMessage = g.ranks.messages(history, err)
+	v.CampRank, v.PlayerRank = g.listRanks()
+	v.Degraded = err != nil || g.db.Degraded()
 	return v, nil
 }
 
@@ -55,11 +65,61 @@ func (g *Game) GetGameStop() GameStop {
 	winner, _ := g.GetWinner()
 	v := GameStop{
 		Winner:        winner,
-		WinnerVotes:   g.db.Player.GetWinnerVotes(g.dbGame.ID, uint8(winner)),
-		NextCountDown: int64(g.cfg.GameRoundInterval),
+		NextCountDown: int64(g.Config().GameRoundInterval),
+	}
+	if g.db.Degraded() {
+		// a player votes by joining, the round's votes are its balls
+		v.WinnerVotes = int64(g.CampVotes()[winner])
+	} else {
+		v.WinnerVotes = g.db.Player.GetWinnerVotes(g.GetGameID(), uint8(winner))
+	}
+	v.CampRank, v.PlayerRank = g.listRanks()
+	return v
+}
+
+func (g *Game) listRanks() ([]model.Camp, []model.Player) {
+	// the ranks are read at round boundaries, on the game loop: while the
+	// database is down they come from the cache without waiting on it
+	if g.db.Degraded() {
+		return g.ranks.camps(nil, errDegraded), g.ranks.players(nil, errDegraded)
 	}
 	rankLimit := 3
-	v.CampRank, _ = g.db.Camp.ListRank(rankLimit)
-	v.PlayerRank, _ = g.db.Player.ListRank(rankLimit)
+	camps, err := g.db.Camp.ListRank(rankLimit)
+	camps = g.ranks.camps(camps, err)
+	players, err := g.db.Player.ListRank(0, rankLimit)
+	players = g.ranks.players(players, err)
+	return camps, players
+}
+
+func (c *rankCache) messages(v []model.Message, err error) []model.Message {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		roomLog().Error("list latest messages failed, serving cache", zap.Error(err))
+		return c.historyMessage
+	}
+	c.historyMessage = v
+	return v
+}
+
+func (c *rankCache) camps(v []model.Camp, err error) []model.Camp {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		roomLog().Error("list camp rank failed, serving cache", zap.Error(err))
+		return c.campRank
+	}
+	c.campRank = v
+	return v
+}
+
+func (c *rankCache) players(v []model.Player, err error) []model.Player {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if err != nil {
+		roomLog().Error("list player rank failed, serving cache", zap.Error(err))
+		return c.playerRank
+	}
+	c.playerRank = v
 	return v
 }
@@ -2,15 +2,22 @@ package game
 
 import (
 	"context"
+	"encoding/binary"
 	"image"
 	"image/color"
 	"image/draw"
 	"image/png"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/COAOX/zecrey_warrior/config"
 	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/model"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
 )
 
 var img = image.NewRGBA(image.Rect(0, 0, 852, 642))
@@ -40,10 +47,10 @@ func Rect(x1, y1, x2, y2 int) {
 
 func TestGame(t *testing.T) {
 	cfg := config.Read("../config/local.json")
-	d := db.NewClient(cfg.Database)
-	g := NewGame(context.Background(), cfg, d, func(winner Camp) {}, func(camp Camp, votes int32) {})
+	d := db.NewMemoryClient()
+	g := NewGame(context.Background(), cfg, d, db.NewWriter(d, db.WriterConfig{}), func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
 
-	new_png_file := "draw.png" // output image will live here
+	new_png_file := filepath.Join(t.TempDir(), "draw.png") // output image will live here
 
 	myimage := image.NewRGBA(image.Rect(0, 0, 852, 642)) // x1,y1,  x2,y2 of background rectangle
 	mygreen := color.RGBA{0, 100, 0, 255}                //  R, G, B, Alpha
@@ -54,8 +61,9 @@ func TestGame(t *testing.T) {
 	// red_rect := image.Rect(60, 80, 120, 160) //  geometry of 2nd rectangle which we draw atop above rectangle
 	myred := color.RGBA{200, 0, 0, 255}
 
-	for _, obj := range g.space.Objects() {
-		draw.Draw(myimage, image.Rect(int(obj.X), int(obj.Y), int(obj.X+obj.W), int(obj.Y+obj.H)), &image.Uniform{myred}, image.ZP, draw.Src)
+	for i := range g.Map.Cells {
+		x, y := cellIndexToSpaceXY(i%mapColumn, i/mapColumn)
+		draw.Draw(myimage, image.Rect(int(x), int(y), int(x+cellWidth), int(y+cellHeight)), &image.Uniform{myred}, image.ZP, draw.Src)
 	}
 
 	myfile, err := os.Create(new_png_file) // ... now lets save output image
@@ -65,3 +73,431 @@ func TestGame(t *testing.T) {
 	defer myfile.Close()
 	png.Encode(myfile, myimage)
 }
+
+func TestCheckpointResume(t *testing.T) {
+	cfg := config.Read("../config/local.json")
+	d := db.NewMemoryClient()
+	w := db.NewWriter(d, db.WriterConfig{})
+	g := NewGame(context.Background(), cfg, d, w, func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
+	g.GameStatus = GameRunning
+	g.roundDeadline = time.Now().Add(time.Minute)
+	g.addPlayer(1, BTC)
+	g.addPlayer(2, ETH)
+	g.addItem(100, 100, ItemAccelerator)
+	g.Map.Cells[0] = MATIC
+	g.Cheer(AVAX)
+	g.checkpoint()
+	if err := w.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewGame(context.Background(), cfg, d, db.NewWriter(d, db.WriterConfig{}), func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
+	if r.GetGameID() != g.GetGameID() {
+		t.Fatalf("resumed game %d, want %d", r.GetGameID(), g.GetGameID())
+	}
+	if r.Map.Cells[0] != MATIC {
+		t.Errorf("cell 0 is %d, want %d", r.Map.Cells[0], MATIC)
+	}
+	if votes := r.CampVotes(); votes[BTC] != 1 || votes[ETH] != 1 {
+		t.Errorf("unexpected votes %v", votes)
+	}
+	if cheers := r.CampCheers(); cheers[AVAX] != 1 {
+		t.Errorf("unexpected cheers %v", cheers)
+	}
+	if _, ok := r.players[2]; !ok {
+		t.Error("player 2 not resumed")
+	}
+	if _, ok := r.items[1]; !ok {
+		t.Error("item 1 not resumed")
+	}
+	if r.roundRemain <= 50*time.Second || r.roundRemain > time.Minute {
+		t.Errorf("remaining %v, want about a minute", r.roundRemain)
+	}
+}
+
+func TestConcurrentCommands(t *testing.T) {
+	cfg := &config.Config{FPS: 30, ItemFrameChance: 10, GameDuration: 600, CheersPerItem: 2, FrontendType: "test"}
+	d := db.NewMemoryClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g := NewGame(ctx, cfg, d, db.NewWriter(d, db.WriterConfig{}), func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
+	g.Start()
+
+	const joins = 200
+	added := make(chan struct{}, joins)
+	for i := 0; i < joins; i++ {
+		go func(id uint64) {
+			g.AddPlayer(id, "", Camp(id%5+1), func() { added <- struct{}{} })
+			g.Cheer(Camp(id%5 + 1))
+		}(uint64(i + 1))
+	}
+	for n := 0; n < joins; n++ {
+		<-added
+	}
+
+	status, err := g.Status()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Players != joins {
+		t.Errorf("%d players in the arena, want %d", status.Players, joins)
+	}
+}
+
+func TestTickRateIndependentSpeed(t *testing.T) {
+	moved := map[int]float64{}
+	for _, rate := range []int{30, 60, 144} {
+		cfg := &config.Config{FPS: 30, TickRate: rate, ItemFrameChance: 1 << 30, GameDuration: 600, FrontendType: "test"}
+		d := db.NewMemoryClient()
+		g := NewGame(context.Background(), cfg, d, db.NewWriter(d, db.WriterConfig{}), func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
+		g.GameStatus = GameRunning
+		paint(g, BTC, 0, 0, mapColumn-1, mapRow-1)
+		place(g, 1, BTC, 10, 10, 1.5, 0.5)
+		x0 := g.players[1].x
+		for i := 0; i < rate; i++ {
+			g.Step()
+		}
+		moved[rate] = g.players[1].x - x0
+	}
+	for rate, dx := range moved {
+		if diff := dx - moved[30]; diff > 1e-6 || diff < -1e-6 {
+			t.Errorf("ball moved %v in a second at %d ticks per second, %v at 30", dx, rate, moved[30])
+		}
+	}
+}
+
+func TestRoundBreak(t *testing.T) {
+	cfg := &config.Config{FPS: 30, ItemFrameChance: 1 << 30, GameDuration: 600, GameRoundInterval: 1, FrontendType: "test"}
+	d := db.NewMemoryClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g := NewGame(ctx, cfg, d, db.NewWriter(d, db.WriterConfig{}), func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
+	g.Start()
+
+	start := time.Now()
+	if err := g.EndRound(); err != nil {
+		t.Fatal(err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("EndRound took %v, it must not wait out the break", d)
+	}
+	if s := g.Snapshot(); s.Status != GameStopped {
+		t.Fatalf("status %v after EndRound, want %v", s.Status, GameStopped)
+	}
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancelWait()
+	if err := g.WaitRoundEnd(waitCtx); err != nil {
+		t.Errorf("WaitRoundEnd between rounds: %v, want it to return right away", err)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for g.Snapshot().Status != GameRunning {
+		if time.Now().After(deadline) {
+			t.Fatal("the next round didn't start")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := g.Status(); err != nil {
+		t.Fatal(err)
+	}
+	if s := g.TickStats(); s.MaxDuration > 500*time.Millisecond || s.Lag > 500*time.Millisecond {
+		t.Errorf("the break shows in the tick stats: max duration %v, lag %v", s.MaxDuration, s.Lag)
+	}
+}
+
+func TestReloadAndAdminDuration(t *testing.T) {
+	g := newTestGame()
+	boot := g.Config()
+	file := func(duration, fps int) *config.Config {
+		c := *boot
+		c.GameDuration, c.FPS = duration, fps
+		return &c
+	}
+	for _, step := range []struct {
+		name      string
+		change    func()
+		duration  int
+		fps       int
+		nextShown int
+	}{
+		{"admin duration", func() { g.SetNextRoundDuration(120) }, 120, 30, 120},
+		{"reload keeping game_duration", func() { g.Reload(file(600, 20)) }, 120, 20, 120},
+		{"next round", func() {}, 120, 20, 120},
+		{"reload changing game_duration", func() { g.Reload(file(300, 20)) }, 300, 20, 300},
+		{"admin duration after the reload", func() { g.Reload(file(300, 25)); g.SetNextRoundDuration(90) }, 90, 25, 90},
+		{"admin duration then reload changing game_duration", func() { g.SetNextRoundDuration(45); g.Reload(file(200, 25)) }, 200, 25, 200},
+	} {
+		step.change()
+		if next := g.nextRoundDuration(); next != step.nextShown {
+			t.Errorf("%s: next round duration %d before the round, want %d", step.name, next, step.nextShown)
+		}
+		g.applyPendingConfig()
+		if c := g.Config(); c.GameDuration != step.duration || c.FPS != step.fps {
+			t.Errorf("%s: game_duration %d fps %d, want %d %d", step.name, c.GameDuration, c.FPS, step.duration, step.fps)
+		}
+	}
+	if boot.GameDuration != 600 || boot.FPS != 30 {
+		t.Errorf("the boot config was modified: game_duration %d fps %d", boot.GameDuration, boot.FPS)
+	}
+}
+
+func TestControlContext(t *testing.T) {
+	// the loop isn't running, the commands are never applied
+	g := newTestGame()
+	g.publish()
+	for name, call := range map[string]func(context.Context) error{
+		"WaitRoundEnd": g.WaitRoundEnd,
+		"Checkpoint":   g.Checkpoint,
+	} {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		start := time.Now()
+		err := call(ctx)
+		cancel()
+		if err != context.DeadlineExceeded {
+			t.Errorf("%s: got %v, want %v", name, err, context.DeadlineExceeded)
+		}
+		if d := time.Since(start); d > time.Second {
+			t.Errorf("%s returned after %v, not on the caller's deadline", name, d)
+		}
+	}
+}
+
+// hungDB stands for a database that stopped answering: its calls block until down is closed.
+type hungDB struct{ down chan struct{} }
+
+type (
+	hungGame struct {
+		db.GameStore
+		hungDB
+	}
+	hungCamp struct {
+		db.CampStore
+		hungDB
+	}
+	hungPlayer struct {
+		db.PlayerStore
+		hungDB
+	}
+	hungMessage struct {
+		db.MessageStore
+		hungDB
+	}
+)
+
+func (h hungGame) Create(game *model.Game) error {
+	<-h.down
+	return h.GameStore.Create(game)
+}
+
+func (h hungGame) Update(game *model.Game) error {
+	<-h.down
+	return h.GameStore.Update(game)
+}
+
+func (h hungGame) Settle(gameID uint, winnerID uint8, endTime time.Time) error {
+	<-h.down
+	return h.GameStore.Settle(gameID, winnerID, endTime)
+}
+
+func (h hungCamp) ListRank(limit int) ([]model.Camp, error) {
+	<-h.down
+	return h.CampStore.ListRank(limit)
+}
+
+func (h hungPlayer) ListRank(offset, size int) ([]model.Player, error) {
+	<-h.down
+	return h.PlayerStore.ListRank(offset, size)
+}
+
+func (h hungPlayer) GetWinnerVotes(gameID uint, winner uint8) int64 {
+	<-h.down
+	return h.PlayerStore.GetWinnerVotes(gameID, winner)
+}
+
+func (h hungMessage) ListLatest(offset, size int) ([]model.Message, error) {
+	<-h.down
+	return h.MessageStore.ListLatest(offset, size)
+}
+
+func TestDegraded(t *testing.T) {
+	cfg := &config.Config{FPS: 30, ItemFrameChance: 1 << 30, GameDuration: 600, GameRoundInterval: 1, FrontendType: "test"}
+	d := db.NewMemoryClient()
+	h := hungDB{down: make(chan struct{})}
+	d.Game, d.Camp = hungGame{d.Game, h}, hungCamp{d.Camp, h}
+	d.Player, d.Message = hungPlayer{d.Player, h}, hungMessage{d.Message, h}
+	d.SetDegraded(true)
+	writer := db.NewWriter(d, db.WriterConfig{})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the room callbacks, they read the ranks and the votes at the round boundaries
+	started, stopped := make(chan GameInfo, 2), make(chan GameStop, 1)
+	var g *Game
+	g = NewGame(ctx, cfg, d, writer,
+		func(context.Context) { info, _ := g.GetGameInfo(); started <- info },
+		func(context.Context) { stopped <- g.GetGameStop() },
+		func(camp Camp, votes int32) {})
+	paint(g, BTC, 0, 0, mapColumn-1, mapRow-1)
+	g.Start()
+	g.AddPlayer(1, "alice", BTC, nil)
+
+	// the round ends, is saved and settled, and the next one starts, without waiting on the database
+	ended := make(chan error, 1)
+	go func() { ended <- g.EndRound() }()
+	select {
+	case err := <-ended:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("EndRound blocked on the unavailable database")
+	}
+	if stop := <-stopped; stop.Winner != BTC || stop.WinnerVotes != 1 {
+		t.Errorf("game stop %+v, want BTC with the round's 1 vote", stop)
+	}
+	select {
+	case info := <-started:
+		if !info.Degraded {
+			t.Error("the game info of a degraded round isn't marked degraded")
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("the next round didn't start")
+	}
+	if id := g.GetGameID(); id != 0 {
+		t.Errorf("game id %d while degraded, the game row is queued", id)
+	}
+
+	// back: the queued game rows are created and the round is settled on retry
+	close(h.down)
+	d.SetDegraded(false)
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer flushCancel()
+	if err := writer.Flush(flushCtx); err != nil {
+		t.Fatal(err)
+	}
+	if id := g.GetGameID(); id == 0 {
+		t.Error("the game row of the current round wasn't created once the database was back")
+	}
+	g.settler.retry()
+	games, err := d.Game.ListHistory(0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settled := 0
+	for _, gm := range games {
+		if gm.Settled {
+			settled++
+		}
+	}
+	if settled != 1 {
+		t.Errorf("%d settled games once the database was back, want the ended round", settled)
+	}
+}
+
+func TestGameStatusValues(t *testing.T) {
+	// sent to the clients, see the const block
+	for status, want := range map[GameStatus]int{GameNotStarted: 7, GameRunning: 8, GameStopped: 9, GamePaused: 10} {
+		if int(status) != want {
+			t.Errorf("status %d, want %d", status, want)
+		}
+	}
+}
+
+func TestSerializeFrame(t *testing.T) {
+	g := newTestGame()
+	g.Step()
+	g.Step()
+	s := NewSerializer()
+	for i := 0; i < 3; i++ {
+		b, err := s.Marshal(g)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if frame := binary.BigEndian.Uint32(b); frame != 2 {
+			t.Errorf("serialized frame %d, want the published frame 2", frame)
+		}
+	}
+	if g.frameNumber != 2 {
+		t.Errorf("frame number %d after serializing, want it untouched", g.frameNumber)
+	}
+}
+
+func TestControlDuringBreak(t *testing.T) {
+	cfg := &config.Config{FPS: 30, ItemFrameChance: 1 << 30, GameDuration: 600, GameRoundInterval: 1, FrontendType: "test"}
+	d := db.NewMemoryClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g := NewGame(ctx, cfg, d, db.NewWriter(d, db.WriterConfig{}), func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
+	g.Start()
+	if err := g.EndRound(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the control calls are answered during the break, not when the next round starts
+	start := time.Now()
+	if s, err := g.Status(); err != nil || s.Status != GameStopped {
+		t.Errorf("status %+v %v during the break, want %v", s, err, GameStopped)
+	}
+	for name, call := range map[string]func() error{
+		"EndRound":     g.EndRound,
+		"RestartRound": g.RestartRound,
+		"Pause":        g.Pause,
+	} {
+		if err := call(); err != ErrNotRunning {
+			t.Errorf("%s during the break: %v, want %v", name, err, ErrNotRunning)
+		}
+	}
+	if _, err := g.SpawnItem(ItemAccelerator, 1, 1); err != ErrNotRunning {
+		t.Errorf("SpawnItem during the break: %v, want %v", err, ErrNotRunning)
+	}
+	if err := g.Checkpoint(context.Background()); err != nil {
+		t.Error(err)
+	}
+	if d := time.Since(start); d > 500*time.Millisecond {
+		t.Errorf("the control calls took %v during the break", d)
+	}
+
+	// a ball queued during the break joins the next round
+	added := make(chan struct{})
+	g.AddPlayer(1, "alice", BTC, func() { close(added) })
+	select {
+	case <-added:
+	case <-time.After(3 * time.Second):
+		t.Fatal("the ball queued during the break wasn't added")
+	}
+	if s, err := g.Status(); err != nil || s.Status != GameRunning || s.Players != 1 {
+		t.Errorf("status %+v %v after the break, want the ball in the running round", s, err)
+	}
+}
+
+func TestControlAbandoned(t *testing.T) {
+	// the loop isn't running: the call times out and must not run on the next frame
+	g := newTestGame()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ran := false
+	if err := g.controlCtx(ctx, func() error { ran = true; return nil }); err != context.DeadlineExceeded {
+		t.Fatalf("got %v, want %v", err, context.DeadlineExceeded)
+	}
+	g.Step()
+	if ran {
+		t.Error("a control call ran after its caller gave up")
+	}
+}
+
+func TestRoundLogFields(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	defer zap.ReplaceGlobals(zap.New(core))()
+
+	g := newTestGame()
+	g.AddPlayer(1, "alice", BTC, nil)
+	g.Step()
+	entries := logs.FilterMessage("new player").All()
+	if len(entries) != 1 {
+		t.Fatalf("%d new player entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["room"] != config.GameRoomName || fields["game_id"] != uint64(g.GetGameID()) || fields["player_id"] != uint64(1) {
+		t.Errorf("fields %v, want room %s, game_id %d and player_id 1", fields, config.GameRoomName, g.GetGameID())
+	}
+}
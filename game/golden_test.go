@@ -0,0 +1,181 @@
+package game
+
+import (
+	"context"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/db"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+var campLetters = map[Camp]byte{Empty: '.', BTC: 'B', ETH: 'E', BNB: 'N', AVAX: 'A', MATIC: 'M'}
+
+// A scenario sets a game up, then runs it for frames frames and records one frame every every frames.
+type scenario struct {
+	name   string
+	frames int
+	every  int
+	setup  func(g *Game)
+}
+
+var scenarios = []scenario{
+	{
+		// a ball bouncing into the top left corner of the map, inside its own camp
+		name: "corner", frames: 150, every: 30,
+		setup: func(g *Game) {
+			paint(g, BTC, 0, 0, 6, 6)
+			place(g, 1, BTC, 2, 2, -1.7, -1.1)
+		},
+	},
+	{
+		// a ball running into an accelerator across its own camp
+		name: "item", frames: 150, every: 30,
+		setup: func(g *Game) {
+			paint(g, ETH, 10, 12, 30, 17)
+			place(g, 1, ETH, 12, 14, 1.2, 0.1)
+			x, y := cellIndexToSpaceXY(18, 14)
+			g.addItem(x, y, ItemAccelerator)
+		},
+	},
+	{
+		// a ball boosted by several accelerators crossing enemy cells
+		name: "high_speed_enemy", frames: 60, every: 10,
+		setup: func(g *Game) {
+			paint(g, BNB, 0, 0, mapColumn-1, mapRow-1)
+			paint(g, AVAX, 0, 0, 4, 4)
+			place(g, 1, AVAX, 2, 2, 7.6, 5.1)
+		},
+	},
+}
+
+func TestGoldenFrames(t *testing.T) {
+	for _, sc := range scenarios {
+		sc := sc
+		t.Run(sc.name, func(t *testing.T) {
+			g := newTestGame()
+			sc.setup(g)
+
+			var out strings.Builder
+			record(&out, g, 0)
+			for frame := 1; frame <= sc.frames; frame++ {
+				g.Step()
+				if frame%sc.every == 0 {
+					record(&out, g, frame)
+				}
+			}
+
+			path := filepath.Join("testdata", sc.name+".golden")
+			if *update {
+				if err := os.WriteFile(path, []byte(out.String()), 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+			want, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatalf("%v, run the tests with -update to create it", err)
+			}
+			compareLines(t, path, string(want), out.String())
+		})
+	}
+}
+
+// newTestGame returns a running game without random items, on the initial map.
+func newTestGame() *Game {
+	cfg := &config.Config{FPS: 30, TickRate: tunedTickRate, ItemFrameChance: 1 << 30, GameDuration: 600, FrontendType: "test"}
+	d := db.NewMemoryClient()
+	g := NewGame(context.Background(), cfg, d, db.NewWriter(d, db.WriterConfig{}), func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
+	g.Seed(1)
+	g.GameStatus = GameRunning
+	return g
+}
+
+// paint gives the cells from x0, y0 to x1, y1 included to camp.
+func paint(g *Game, camp Camp, x0, y0, x1, y1 int) {
+	cells := append([]Camp(nil), g.Map.Cells...)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			cells[y*mapColumn+x] = camp
+		}
+	}
+	g.initMapWith(cells)
+}
+
+// place puts a ball on cell x, y with the given velocity.
+func place(g *Game, id uint64, camp Camp, x, y int, vx, vy float64) {
+	sx, sy := cellIndexToSpaceXY(x, y)
+	g.players[id] = &Player{ID: id, Camp: camp, R: defaultPlayerPixelR, Vx: vx, Vy: vy, x: sx, y: sy}
+}
+
+// record writes the balls, items and cells of g, then its serialized frame.
+func record(out *strings.Builder, g *Game, frame int) {
+	fmt.Fprintf(out, "frame %d\n", frame)
+
+	players := []*Player{}
+	for _, p := range g.players {
+		players = append(players, p)
+	}
+	sort.Slice(players, func(i, j int) bool { return players[i].ID < players[j].ID })
+	for _, p := range players {
+		x, y := space2MapXY(p.GetCenter())
+		fmt.Fprintf(out, "ball %d %s x=%.4f y=%.4f vx=%.4f vy=%.4f\n", p.ID, CampTagMap[p.Camp], x, y, p.Vx, p.Vy)
+	}
+	items := []*ItemObject{}
+	for _, i := range g.items {
+		items = append(items, i)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].Id < items[j].Id })
+	for _, i := range items {
+		x, y := space2MapXY(i.Center())
+		fmt.Fprintf(out, "item %d %s x=%.4f y=%.4f\n", i.Id, i.Item.Name, x, y)
+	}
+
+	out.WriteString("cells\n")
+	for y := 0; y < mapRow; y++ {
+		for x := 0; x < mapColumn; x++ {
+			out.WriteByte(campLetters[g.Map.Cells[y*mapColumn+x]])
+		}
+		out.WriteByte('\n')
+	}
+
+	b := g.snapshot().Serialize()
+	out.WriteString("bytes\n")
+	for len(b) > 0 {
+		n := 32
+		if len(b) < n {
+			n = len(b)
+		}
+		out.WriteString(hex.EncodeToString(b[:n]))
+		out.WriteByte('\n')
+		b = b[n:]
+	}
+}
+
+// compareLines reports the first line where got differs from want.
+func compareLines(t *testing.T, path, want, got string) {
+	if want == got {
+		return
+	}
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	for i := 0; i < len(w) || i < len(g); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			t.Fatalf("%s:%d differs, run the tests with -update if the change is intended\nwant: %s\ngot:  %s", path, i+1, wl, gl)
+		}
+	}
+}
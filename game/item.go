@@ -1,22 +1,11 @@
 package game
 
-import (
-	"bytes"
-	"encoding/binary"
-	"fmt"
-	"math/rand"
-	"time"
-
-	"github.com/solarlune/resolv"
-)
-
 type ItemType uint8
 
 const (
 	itemPixelR = 15
 
 	ItemAccelerator ItemType = iota
-	ItemTag                  = "ITEM"
 
 	AcceleratorTag = "Accelerator"
 
@@ -60,46 +49,39 @@ type ItemObject struct {
 }
 
 func (p *ItemObject) Serialize() []byte {
-	bytesBuffer := bytes.NewBuffer(make([]byte, 0))
-	binary.Write(bytesBuffer, binary.BigEndian, p.Id)
-	binary.Write(bytesBuffer, binary.BigEndian, uint8(p.Item.Type))
-	x, y := space2MapXY(p.Center())
-	binary.Write(bytesBuffer, binary.BigEndian, x)
-	binary.Write(bytesBuffer, binary.BigEndian, y)
-	return bytesBuffer.Bytes()
+	return p.state().Serialize()
 }
 
-func (i *ItemObject) Center() (float64, float64) {
-	return i.X + float64(itemPixelR), i.Y + float64(itemPixelR)
+func (p *ItemObject) state() ItemState {
+	return ItemState{ID: p.Id, Type: p.Item.Type, X: p.X, Y: p.Y}
 }
 
-func itemIdToTag(Id uint32) string {
-	return fmt.Sprintf("%s_%d", ItemTag, Id)
-}
-
-func itemTagsToId(tags []string) uint32 {
-	var id uint32
-
-	for _, tag := range tags {
-		if tag[:len(ItemTag)] == ItemTag {
-			fmt.Sscanf(tag, "%s_%d", &id)
-		}
-	}
-	return id
+func (i *ItemObject) Center() (float64, float64) {
+	return i.X + float64(itemPixelR), i.Y + float64(itemPixelR)
 }
 
 func (g *Game) TryAddItem() {
-	if g.GameStatus != GameRunning || rand.Intn(g.cfg.ItemFrameChance) != 1 {
+	// item_frame_chance is per tick at tunedTickRate
+	chance := g.Config().ItemFrameChance * g.tickRate / tunedTickRate
+	if chance < 1 {
+		chance = 1
+	}
+	if g.GameStatus != GameRunning || g.rnd.Intn(chance) != 1 {
 		return
 	}
-	x, y := g.Map.RandomSpaceXY()
-	g.space.Add(resolv.NewObject(x, y, float64(2*itemPixelR), float64(2*itemPixelR), ItemTag, ItemTagMap[ItemAccelerator]))
+	x, y := g.Map.RandomSpaceXY(g.rnd)
+	g.addItem(x, y, ItemAccelerator)
+}
+
+// addItem places an item with its top left corner at space coordinate x, y.
+func (g *Game) addItem(x, y float64, itemType ItemType) *ItemObject {
 	item := &ItemObject{
-		Id:   uint32(time.Now().UnixMilli()),
+		Id:   g.itemSeq + 1,
 		X:    x,
 		Y:    y,
-		Item: ItemMap[ItemAccelerator],
+		Item: ItemMap[itemType],
 	}
-
-	g.Items.LoadOrStore(item.Id, item)
+	g.itemSeq++
+	g.items[item.Id] = item
+	return item
 }
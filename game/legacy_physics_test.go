@@ -0,0 +1,171 @@
+package game
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/kvartborg/vector"
+	"github.com/solarlune/resolv"
+)
+
+// The resolv based physics the game used before physics.go, kept to benchmark against.
+
+const (
+	legacyCellTag           = "CELL"
+	legacyEdgeTag           = "EDGE"
+	legacyHorizontalEdgeTag = "HORIZONTAL"
+	legacyVerticalEdgeTag   = "VERTICAL"
+	legacyPlayerTag         = "Player"
+)
+
+type legacyWorld struct {
+	space *resolv.Space
+	cells []Camp
+	balls []*legacyBall
+}
+
+type legacyBall struct {
+	camp   Camp
+	r      int
+	vx, vy float64
+	obj    *resolv.Object
+}
+
+func newLegacyWorld(cells []Camp) *legacyWorld {
+	w := &legacyWorld{cells: append([]Camp(nil), cells...)}
+	m := NewMap()
+	w.space = resolv.NewSpace(int(m.W())+2*edgeWidth, int(m.H())+2*edgeWidth, edgeWidth, edgeWidth)
+	w.space.Add(resolv.NewObject(0, 0, m.W()+edgeWidth, edgeWidth, legacyEdgeTag, legacyHorizontalEdgeTag))
+	w.space.Add(resolv.NewObject(0, edgeWidth, edgeWidth, m.W()+edgeWidth, legacyEdgeTag, legacyVerticalEdgeTag))
+	w.space.Add(resolv.NewObject(m.W()+edgeWidth, 0, edgeWidth, m.H()+edgeWidth, legacyEdgeTag, legacyVerticalEdgeTag))
+	w.space.Add(resolv.NewObject(edgeWidth, m.H()+edgeWidth, m.W()+edgeWidth, edgeWidth, legacyEdgeTag, legacyHorizontalEdgeTag))
+	for y := 0; y < mapRow; y++ {
+		for x := 0; x < mapColumn; x++ {
+			ox, oy := cellIndexToSpaceXY(x, y)
+			w.space.Add(resolv.NewObject(ox, oy, float64(cellWidth), float64(cellHeight), CampTagMap[w.cells[y*mapColumn+x]], legacyCellTag, fmt.Sprintf("%d,%d", x, y)))
+		}
+	}
+	return w
+}
+
+// add puts a ball with its top left corner at space coordinate x, y.
+func (w *legacyWorld) add(camp Camp, x, y, vx, vy float64) {
+	b := &legacyBall{camp: camp, r: defaultPlayerPixelR, vx: vx, vy: vy}
+	b.obj = resolv.NewObject(x, y, float64(2*b.r), float64(2*b.r), legacyPlayerTag)
+	w.space.Add(b.obj)
+	w.balls = append(w.balls, b)
+}
+
+func (w *legacyWorld) update() {
+	for _, b := range w.balls {
+		remainX, remainY := b.vx, b.vy
+		change := false
+		for steps := 0; (remainX != 0 || remainY != 0) && steps < maxCollisionSteps; steps++ {
+			dx, dy := remainX, remainY
+			if collision := b.obj.Check(dx, dy, legacyCollisionTags(b.camp)...); collision != nil {
+				obj := collision.Objects[0]
+				dx, dy = legacyResolvDxDy(dx, dy, collision.ContactWithObject(obj))
+				if obj.HasTags(legacyCellTag) {
+					remainX, remainY = b.rebound(dx, dy, remainX, remainY, obj)
+					if !change {
+						change = true
+						x, y := legacyCellIndex(obj.Tags())
+						w.cells[y*mapColumn+x] = b.camp
+						for _, tag := range obj.Tags() {
+							if _, ok := CampTagMapReverse[tag]; ok {
+								obj.RemoveTags(tag)
+							}
+						}
+						obj.AddTags(CampTagMap[b.camp])
+					}
+				} else if obj.HasTags(legacyHorizontalEdgeTag) {
+					b.vy = -b.vy
+					remainX -= dx
+					remainY = dy - remainY
+				} else {
+					b.vx = -b.vx
+					remainX = dx - remainX
+					remainY -= dy
+				}
+			} else {
+				remainX -= dx
+				remainY -= dy
+			}
+			b.obj.X += dx
+			b.obj.Y += dy
+			b.obj.Update()
+		}
+	}
+}
+
+func (b *legacyBall) rebound(dx, dy, rx, ry float64, cell *resolv.Object) (float64, float64) {
+	nx, ny := b.obj.X+dx, b.obj.Y+dy
+	rx -= dx
+	ry -= dy
+	if ny <= cell.Y-float64(2*b.r) || ny >= cell.Y+cell.H {
+		b.vy *= -1
+		ry *= -1
+	}
+	if nx <= cell.X-float64(2*b.r) || nx >= cell.X+cell.W {
+		b.vx *= -1
+		rx *= -1
+	}
+	return rx, ry
+}
+
+func legacyCollisionTags(camp Camp) []string {
+	tags := []string{legacyEdgeTag}
+	for c, tag := range CampTagMap {
+		if c != camp {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+func legacyCellIndex(tags []string) (int, int) {
+	for _, tag := range tags {
+		s := strings.Split(tag, ",")
+		if len(s) == 2 {
+			x, _ := strconv.Atoi(s[0])
+			y, _ := strconv.Atoi(s[1])
+			return x, y
+		}
+	}
+	return 0, 0
+}
+
+func legacyResolvDxDy(dx, dy float64, cvector vector.Vector) (x float64, y float64) {
+	x, y = dx, dy
+	cx, cy := cvector.X(), cvector.Y()
+	xDistance, yDistance := float64(1), float64(1)
+	if (cx < 0 && dx < cx) || (cx > 0 && dx > cx) {
+		xDistance = cx / dx
+	}
+	if cx == 0 {
+		if x == 0 {
+			xDistance = 1
+		} else {
+			xDistance = 0
+		}
+	}
+	if (cy < 0 && dy < cy) || (cy > 0 && dy > cy) {
+		yDistance = cy / dy
+	}
+	if cy == 0 {
+		if y == 0 {
+			yDistance = 1
+		} else {
+			yDistance = 0
+		}
+	}
+	if xDistance < yDistance {
+		y *= xDistance
+		x *= xDistance
+	} else {
+		x *= yDistance
+		y *= yDistance
+	}
+	return
+}
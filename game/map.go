@@ -52,8 +52,8 @@ func (m *Map) OutofMap(x, y float64) bool {
 	return x < 0 || x > m.W() || y < 0 || y > m.H()
 }
 
-func (m *Map) RandomSpaceXY() (float64, float64) {
-	x := rand.Intn(mapColumn)*(cellWidth+lineWidth) + edgeWidth
-	y := rand.Intn(mapRow)*(cellHeight+lineWidth) + edgeWidth
+func (m *Map) RandomSpaceXY(rnd *rand.Rand) (float64, float64) {
+	x := rnd.Intn(mapColumn)*(cellWidth+lineWidth) + edgeWidth
+	y := rnd.Intn(mapRow)*(cellHeight+lineWidth) + edgeWidth
 	return float64(x), float64(y)
 }
@@ -0,0 +1,60 @@
+package game
+
+import (
+	"github.com/COAOX/zecrey_warrior/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	roundPlayers = prometheus.NewDesc(metrics.Namespace+"_round_players", "Balls in the arena this round.", nil, nil)
+	roundItems   = prometheus.NewDesc(metrics.Namespace+"_round_items", "Items on the map.", nil, nil)
+	roundCells   = prometheus.NewDesc(metrics.Namespace+"_round_cells", "Cells owned by a camp.", []string{"camp"}, nil)
+	roundVotes   = prometheus.NewDesc(metrics.Namespace+"_round_votes", "Votes for a camp this round.", []string{"camp"}, nil)
+	roundCheers  = prometheus.NewDesc(metrics.Namespace+"_round_cheers", "Cheers for a camp this round.", []string{"camp"}, nil)
+	roundStatus  = prometheus.NewDesc(metrics.Namespace+"_round_status", "Status of the round, see GameStatus.", nil, nil)
+	tickLag      = prometheus.NewDesc(metrics.Namespace+"_tick_lag_seconds", "How late the last tick started.", nil, nil)
+	tickRate     = prometheus.NewDesc(metrics.Namespace+"_tick_rate", "Ticks per second the game loop runs at.", nil, nil)
+	tickTotal    = prometheus.NewDesc(metrics.Namespace+"_ticks_total", "Ticks run by the game loop.", nil, nil)
+	tickOverruns = prometheus.NewDesc(metrics.Namespace+"_tick_overruns_total", "Ticks that took longer than the tick interval.", nil, nil)
+	tickSkipped  = prometheus.NewDesc(metrics.Namespace+"_ticks_skipped_total", "Ticks dropped because the loop was too far behind.", nil, nil)
+)
+
+// collector reads the published snapshot and the tick stats of a game when scraped,
+// the game loop doesn't pay for the metrics.
+type collector struct {
+	g *Game
+}
+
+func (c collector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{roundPlayers, roundItems, roundCells, roundVotes, roundCheers, roundStatus, tickLag, tickRate, tickTotal, tickOverruns, tickSkipped} {
+		ch <- d
+	}
+}
+
+func (c collector) Collect(ch chan<- prometheus.Metric) {
+	snap := c.g.Snapshot()
+	ch <- prometheus.MustNewConstMetric(roundPlayers, prometheus.GaugeValue, float64(len(snap.Players)))
+	ch <- prometheus.MustNewConstMetric(roundItems, prometheus.GaugeValue, float64(len(snap.Items)))
+	ch <- prometheus.MustNewConstMetric(roundStatus, prometheus.GaugeValue, float64(snap.Status))
+
+	cells := map[Camp]int{}
+	for _, camp := range snap.Cells {
+		cells[camp]++
+	}
+	cheers := c.g.CampCheers()
+	for camp, tag := range CampTagMap {
+		if camp == Empty {
+			continue
+		}
+		ch <- prometheus.MustNewConstMetric(roundCells, prometheus.GaugeValue, float64(cells[camp]), tag)
+		ch <- prometheus.MustNewConstMetric(roundVotes, prometheus.GaugeValue, float64(snap.CampVotes[camp]), tag)
+		ch <- prometheus.MustNewConstMetric(roundCheers, prometheus.GaugeValue, float64(cheers[camp]), tag)
+	}
+
+	s := c.g.TickStats()
+	ch <- prometheus.MustNewConstMetric(tickLag, prometheus.GaugeValue, s.Lag.Seconds())
+	ch <- prometheus.MustNewConstMetric(tickRate, prometheus.GaugeValue, float64(s.Rate))
+	ch <- prometheus.MustNewConstMetric(tickTotal, prometheus.CounterValue, float64(s.Ticks))
+	ch <- prometheus.MustNewConstMetric(tickOverruns, prometheus.CounterValue, float64(s.Overruns))
+	ch <- prometheus.MustNewConstMetric(tickSkipped, prometheus.CounterValue, float64(s.Skipped))
+}
@@ -0,0 +1,33 @@
+package game
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestCollector(t *testing.T) {
+	g := newTestGame()
+	paint(g, BTC, 0, 0, mapColumn-1, mapRow-1)
+	paint(g, ETH, 0, 0, 9, 0)
+	place(g, 1, BTC, 100, 100, 1, 0)
+	g.publish()
+
+	want := `
+# HELP zecrey_round_cells Cells owned by a camp.
+# TYPE zecrey_round_cells gauge
+zecrey_round_cells{camp="AVAX"} 0
+zecrey_round_cells{camp="BNB"} 0
+zecrey_round_cells{camp="BTC"} ` + strconv.Itoa(mapColumn*mapRow-10) + `
+zecrey_round_cells{camp="ETH"} 10
+zecrey_round_cells{camp="MATIC"} 0
+# HELP zecrey_round_players Balls in the arena this round.
+# TYPE zecrey_round_players gauge
+zecrey_round_players 1
+`
+	if err := testutil.CollectAndCompare(collector{g}, strings.NewReader(want), "zecrey_round_cells", "zecrey_round_players"); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,241 @@
+package game
+
+import "math"
+
+// Balls are circles moving over the cell grid. Each tick a ball is swept along its
+// velocity: the earliest contact with an enemy cell, an edge of the map or an item
+// is found exactly, the ball is moved there and reflected about the contact normal,
+// then swept again with the rest of its move.
+
+const cellPitch = cellWidth + lineWidth
+
+type hitKind uint8
+
+const (
+	hitNone hitKind = iota
+	hitCell
+	hitEdge
+	hitItem
+)
+
+// hit is a contact found by sweep, at fraction t of the move, with the unit normal
+// nx, ny pointing from the obstacle to the ball.
+type hit struct {
+	kind   hitKind
+	t      float64
+	nx, ny float64
+	cell   int    // index in Map.Cells, for hitCell
+	item   uint32 // id, for hitItem
+}
+
+// move advances p by one tick of its velocity.
+func (g *Game) move(p *Player) {
+	r := float64(p.R)
+	t := 1.0 // fraction of the tick left
+	converted := false
+	for steps := 0; t > 0 && steps < maxCollisionSteps; steps++ {
+		dx, dy := p.Vx*g.velocityScale*t, p.Vy*g.velocityScale*t
+		cx, cy := p.x+r, p.y+r
+		h := g.sweep(p.Camp, cx, cy, dx, dy, r)
+		if h.kind == hitNone {
+			p.x += dx
+			p.y += dy
+			return
+		}
+		p.x += dx * h.t
+		p.y += dy * h.t
+		t *= 1 - h.t
+
+		switch h.kind {
+		case hitItem:
+			if item, ok := g.items[h.item]; ok && item.Item.Type == ItemAccelerator {
+				p.Vx *= 1.5
+				p.Vy *= 1.5
+			}
+			delete(g.items, h.item)
+		case hitCell:
+			// a ball takes at most one cell per tick
+			if !converted {
+				converted = true
+				g.Map.Cells[h.cell] = p.Camp
+				p.taken++
+			}
+			p.Vx, p.Vy = reflect(p.Vx, p.Vy, h.nx, h.ny)
+		case hitEdge:
+			p.Vx, p.Vy = reflect(p.Vx, p.Vy, h.nx, h.ny)
+		}
+	}
+}
+
+// sweep returns the first contact of a ball of camp, centered on cx, cy with radius r,
+// moving by dx, dy. Cells of camp are passed through.
+func (g *Game) sweep(camp Camp, cx, cy, dx, dy, r float64) hit {
+	best := hit{kind: hitNone, t: math.Inf(1)}
+
+	// the edges of the map
+	left, top := float64(edgeWidth)+r, float64(edgeWidth)+r
+	right, bottom := float64(edgeWidth)+g.Map.W()-r, float64(edgeWidth)+g.Map.H()-r
+	if dx < 0 {
+		best.closer(hitEdge, wallTime(cx, dx, left), 1, 0)
+	} else if dx > 0 {
+		best.closer(hitEdge, wallTime(cx, dx, right), -1, 0)
+	}
+	if dy < 0 {
+		best.closer(hitEdge, wallTime(cy, dy, top), 0, 1)
+	} else if dy > 0 {
+		best.closer(hitEdge, wallTime(cy, dy, bottom), 0, -1)
+	}
+
+	// the cells under the swept circle
+	x0, x1 := cellRange(math.Min(cx, cx+dx)-r, math.Max(cx, cx+dx)+r, mapColumn)
+	y0, y1 := cellRange(math.Min(cy, cy+dy)-r, math.Max(cy, cy+dy)+r, mapRow)
+	for y := y0; y <= y1; y++ {
+		for x := x0; x <= x1; x++ {
+			i := y*mapColumn + x
+			if g.Map.Cells[i] == camp {
+				continue
+			}
+			rx, ry := cellIndexToSpaceXY(x, y)
+			if t, nx, ny, ok := sweepRect(cx, cy, dx, dy, rx, ry, rx+cellWidth, ry+cellHeight, r); ok && t < best.t {
+				best = hit{kind: hitCell, t: t, nx: nx, ny: ny, cell: i}
+			}
+		}
+	}
+
+	// items are picked up on contact, the ball doesn't bounce off them
+	for id, item := range g.items {
+		ix, iy := item.Center()
+		t, ok := sweepCircle(cx, cy, dx, dy, ix, iy, r+itemPixelR)
+		if ox, oy := cx-ix, cy-iy; ox*ox+oy*oy <= (r+itemPixelR)*(r+itemPixelR) {
+			t, ok = 0, true
+		}
+		if ok && (t < best.t || (t == best.t && best.kind == hitItem && id < best.item)) {
+			best = hit{kind: hitItem, t: t, item: id}
+		}
+	}
+
+	if best.t > 1 {
+		return hit{kind: hitNone}
+	}
+	return best
+}
+
+func (h *hit) closer(kind hitKind, t, nx, ny float64) {
+	if t >= 0 && t < h.t {
+		*h = hit{kind: kind, t: t, nx: nx, ny: ny}
+	}
+}
+
+// wallTime returns when a center at p moving by d reaches the coordinate wall, 0 if it is past it.
+func wallTime(p, d, wall float64) float64 {
+	if (d < 0 && p <= wall) || (d > 0 && p >= wall) {
+		return 0
+	}
+	return (wall - p) / d
+}
+
+// cellRange returns the first and last cell indexes, clamped to the map, covering the
+// space coordinates lo to hi along an axis of n cells.
+func cellRange(lo, hi float64, n int) (int, int) {
+	first := int(math.Floor((lo - edgeWidth) / cellPitch))
+	last := int(math.Floor((hi - edgeWidth) / cellPitch))
+	return clampInt(first, 0, n-1), clampInt(last, 0, n-1)
+}
+
+// sweepRect returns the fraction of the move dx, dy at which a circle of radius r
+// centered on px, py touches the rectangle x0, y0, x1, y1, and the contact normal.
+// A circle already overlapping the rectangle hits it at 0 if it moves further in.
+func sweepRect(px, py, dx, dy, x0, y0, x1, y1, r float64) (t, nx, ny float64, ok bool) {
+	// overlapping at the start
+	ox, oy := px-clamp(px, x0, x1), py-clamp(py, y0, y1)
+	if d2 := ox*ox + oy*oy; d2 < r*r {
+		if d2 == 0 {
+			return 0, 0, 0, false
+		}
+		d := math.Sqrt(d2)
+		nx, ny = ox/d, oy/d
+		return 0, nx, ny, dx*nx+dy*ny < 0
+	}
+
+	// the faces: a ray against the rectangle grown by r
+	tmin, tmax := 0.0, 1.0
+	enterX, okX := slab(px, dx, x0-r, x1+r, &tmin, &tmax)
+	enterY, okY := slab(py, dy, y0-r, y1+r, &tmin, &tmax)
+	if !okX || !okY {
+		return 0, 0, 0, false
+	}
+	if enterY != 0 {
+		nx, ny = 0, enterY
+	} else {
+		nx, ny = enterX, 0
+	}
+	hx, hy := px+dx*tmin, py+dy*tmin
+	if (nx != 0 && hy >= y0 && hy <= y1) || (ny != 0 && hx >= x0 && hx <= x1) {
+		return tmin, nx, ny, true
+	}
+
+	// the corners: a ray against circles of radius r on them
+	t = math.Inf(1)
+	for _, c := range [4][2]float64{{x0, y0}, {x1, y0}, {x0, y1}, {x1, y1}} {
+		if tc, touched := sweepCircle(px, py, dx, dy, c[0], c[1], r); touched && tc < t {
+			t = tc
+			nx, ny = (px+dx*tc-c[0])/r, (py+dy*tc-c[1])/r
+		}
+	}
+	return t, nx, ny, !math.IsInf(t, 1)
+}
+
+// slab clips tmin, tmax to when p moving by d is between lo and hi along one axis.
+// It returns the entry normal along the axis if the entry is the latest so far, else 0.
+func slab(p, d, lo, hi float64, tmin, tmax *float64) (float64, bool) {
+	if d == 0 {
+		return 0, p >= lo && p <= hi
+	}
+	t1, t2, normal := (lo-p)/d, (hi-p)/d, -1.0
+	if t1 > t2 {
+		t1, t2, normal = t2, t1, 1
+	}
+	if t1 >= *tmin {
+		*tmin = t1
+	} else {
+		normal = 0
+	}
+	if t2 < *tmax {
+		*tmax = t2
+	}
+	return normal, *tmin <= *tmax
+}
+
+// sweepCircle returns the fraction of the move dx, dy at which a point at px, py gets
+// within r of cx, cy, a point already within r hits at 0 if it moves closer.
+func sweepCircle(px, py, dx, dy, cx, cy, r float64) (float64, bool) {
+	ox, oy := px-cx, py-cy
+	c := ox*ox + oy*oy - r*r
+	b := ox*dx + oy*dy
+	if c <= 0 {
+		return 0, b < 0
+	}
+	a := dx*dx + dy*dy
+	if a == 0 || b >= 0 {
+		return 0, false
+	}
+	disc := b*b - a*c
+	if disc < 0 {
+		return 0, false
+	}
+	t := (-b - math.Sqrt(disc)) / a
+	return t, t >= 0 && t <= 1
+}
+
+// reflect returns vx, vy mirrored about the unit normal nx, ny, if it moves against it.
+func reflect(vx, vy, nx, ny float64) (float64, float64) {
+	dot := vx*nx + vy*ny
+	if dot >= 0 {
+		return vx, vy
+	}
+	return vx - 2*dot*nx, vy - 2*dot*ny
+}
+
+func clamp(v, lo, hi float64) float64 {
+	return math.Max(lo, math.Min(v, hi))
+}
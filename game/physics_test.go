@@ -0,0 +1,169 @@
+package game
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/COAOX/zecrey_warrior/config"
+)
+
+func TestNoTunneling(t *testing.T) {
+	g := newTestGame()
+	paint(g, BTC, 0, 0, mapColumn-1, mapRow-1)
+	paint(g, ETH, 20, 10, 20, 10)
+	// far faster than a cell is wide
+	place(g, 1, BTC, 5, 10, 60, 0)
+	for i := 0; i < 10; i++ {
+		g.Update()
+	}
+	if g.Map.Cells[10*mapColumn+20] != BTC {
+		t.Error("the ball went through the enemy cell without taking it")
+	}
+	if p := g.players[1]; p.Vx >= 0 {
+		t.Errorf("the ball didn't bounce back, vx=%v", p.Vx)
+	}
+}
+
+func TestCornerReflection(t *testing.T) {
+	g := newTestGame()
+	paint(g, BTC, 0, 0, mapColumn-1, mapRow-1)
+	paint(g, ETH, 20, 10, 20, 10)
+	// aimed at the top left corner of the cell, along its diagonal
+	cx, cy := cellIndexToSpaceXY(20, 10)
+	r := float64(defaultPlayerPixelR)
+	g.players[1] = &Player{ID: 1, Camp: BTC, R: defaultPlayerPixelR, Vx: 1, Vy: 1, x: cx - 30 - r, y: cy - 30 - r}
+	for i := 0; i < 40; i++ {
+		g.Update()
+	}
+	p := g.players[1]
+	if math.Abs(p.Vx+1) > 1e-9 || math.Abs(p.Vy+1) > 1e-9 {
+		t.Errorf("vx=%v vy=%v after hitting the corner head on, want -1 -1", p.Vx, p.Vy)
+	}
+}
+
+func TestBallsStayOut(t *testing.T) {
+	g := newTestGame()
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < mapColumn*mapRow; i++ {
+		g.Map.Cells[i] = Camp(rnd.Intn(int(MATIC)) + 1)
+	}
+	addBalls(g, rnd, 100)
+	// balls of another camp would take the cells under each other
+	for _, p := range g.players {
+		p.Camp = BTC
+		cx, cy := p.GetCenter()
+		g.Map.Cells[int(cy-edgeWidth)/cellPitch*mapColumn+int(cx-edgeWidth)/cellPitch] = BTC
+	}
+	for tick := 0; tick < 600; tick++ {
+		g.Update()
+		for _, p := range g.players {
+			cx, cy := p.GetCenter()
+			r := float64(p.R) - 1e-6
+			if cx < edgeWidth+r || cx > edgeWidth+g.Map.W()-r || cy < edgeWidth+r || cy > edgeWidth+g.Map.H()-r {
+				t.Fatalf("tick %d: ball %d left the map at %v, %v", tick, p.ID, cx, cy)
+			}
+			for i, camp := range g.Map.Cells {
+				if camp == p.Camp {
+					continue
+				}
+				x0, y0 := cellIndexToSpaceXY(i%mapColumn, i/mapColumn)
+				ox, oy := cx-clamp(cx, x0, x0+cellWidth), cy-clamp(cy, y0, y0+cellHeight)
+				if ox*ox+oy*oy < r*r {
+					t.Fatalf("tick %d: ball %d at %v, %v is inside cell %d", tick, p.ID, cx, cy, i)
+				}
+			}
+		}
+	}
+}
+
+func TestBallCollisions(t *testing.T) {
+	for _, tc := range []struct {
+		name         string
+		mode         string
+		opposingOnly bool
+		camp         Camp
+		wantVx1      float64
+		wantVx2      float64
+	}{
+		{name: "off", mode: config.BallCollisionOff, camp: ETH, wantVx1: 2, wantVx2: -1},
+		{name: "bounce", mode: config.BallCollisionBounce, camp: ETH, wantVx1: -1, wantVx2: 2},
+		{name: "steal", mode: config.BallCollisionSteal, camp: ETH, wantVx1: -2.25, wantVx2: 0.75},
+		{name: "same camp", mode: config.BallCollisionBounce, opposingOnly: true, camp: BTC, wantVx1: 2, wantVx2: -1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			g := newTestGame()
+			cfg := *g.Config()
+			cfg.BallCollisions = config.BallCollisionConfig{Mode: tc.mode, OpposingOnly: tc.opposingOnly}
+			g.cfg.Store(&cfg)
+			// balls that met halfway through their last moves
+			g.players[1] = &Player{ID: 1, Camp: BTC, R: defaultPlayerPixelR, Vx: 2, startX: 100, x: 102, startY: 300, y: 300}
+			g.players[2] = &Player{ID: 2, Camp: tc.camp, R: defaultPlayerPixelR, Vx: -1, startX: 111, x: 110, startY: 300, y: 300}
+			g.clash([]uint64{1, 2})
+			if vx := g.players[1].Vx; math.Abs(vx-tc.wantVx1) > 1e-9 {
+				t.Errorf("ball 1 vx=%v, want %v", vx, tc.wantVx1)
+			}
+			if vx := g.players[2].Vx; math.Abs(vx-tc.wantVx2) > 1e-9 {
+				t.Errorf("ball 2 vx=%v, want %v", vx, tc.wantVx2)
+			}
+		})
+	}
+}
+
+// addBalls places n fast balls, of random camps, on cells of their camp.
+func addBalls(g *Game, rnd *rand.Rand, n int) {
+	for id := uint64(1); id <= uint64(n); id++ {
+		i := rnd.Intn(len(g.Map.Cells))
+		x, y := cellIndexToSpaceXY(i%mapColumn, i/mapColumn)
+		ang := rnd.Float64() * 2 * math.Pi
+		speed := 1 + rnd.Float64()*6
+		g.players[id] = &Player{ID: id, Camp: g.Map.Cells[i], R: defaultPlayerPixelR, Vx: math.Cos(ang) * speed, Vy: math.Sin(ang) * speed, x: x + 5, y: y + 5}
+	}
+}
+
+// benchmarkMap is a map of small patches of every camp, where balls hit cells often.
+func benchmarkMap() []Camp {
+	cells := make([]Camp, mapColumn*mapRow)
+	for i := range cells {
+		x, y := i%mapColumn, i/mapColumn
+		cells[i] = Camp((x/3+y/3)%int(MATIC) + 1)
+	}
+	return cells
+}
+
+func BenchmarkUpdate(b *testing.B) {
+	g := newTestGame()
+	g.initMapWith(benchmarkMap())
+	addBalls(g, rand.New(rand.NewSource(1)), 200)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Update()
+	}
+}
+
+func BenchmarkUpdateBallCollisions(b *testing.B) {
+	g := newTestGame()
+	cfg := *g.Config()
+	cfg.BallCollisions.Mode = config.BallCollisionBounce
+	g.cfg.Store(&cfg)
+	g.initMapWith(benchmarkMap())
+	addBalls(g, rand.New(rand.NewSource(1)), 500)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		g.Update()
+	}
+}
+
+func BenchmarkLegacyUpdate(b *testing.B) {
+	w := newLegacyWorld(benchmarkMap())
+	g := newTestGame()
+	g.initMapWith(w.cells)
+	addBalls(g, rand.New(rand.NewSource(1)), 200)
+	for _, p := range g.players {
+		w.add(p.Camp, p.x, p.y, p.Vx, p.Vy)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		w.update()
+	}
+}
@@ -1,17 +1,13 @@
 package game
 
 import (
-	"bytes"
-	"encoding/binary"
-	"fmt"
 	"math"
-	"math/rand"
 
-	"github.com/solarlune/resolv"
+	"github.com/COAOX/zecrey_warrior/logging"
+	"go.uber.org/zap"
 )
 
 const (
-	PlayerTag           = "Player"
 	defaultPlayerPixelR = 5
 )
 
@@ -25,24 +21,19 @@ type Player struct {
 	Vx float64 `json:"vx"`
 	Vy float64 `json:"vy"`
 
-	playerObj *resolv.Object
+	x, y  float64 // top left corner, in space coordinates
+	taken int     // cells converted this round
+
+	startX, startY float64 // x, y before the ball moved this tick
+	bounds         clashBounds
 }
 
-// ID 8 byte
-// R 2 byte
-// X 8 byte
-// Y 8 byte
 func (p *Player) Serialize() []byte {
-	bytesBuffer := bytes.NewBuffer(make([]byte, 0))
-	binary.Write(bytesBuffer, binary.BigEndian, p.ID)
-	binary.Write(bytesBuffer, binary.BigEndian, uint16(p.R))
-	x, y := float64(0), float64(0)
-	if p.playerObj != nil {
-		x, y = space2MapXY(p.GetCenter())
-	}
-	binary.Write(bytesBuffer, binary.BigEndian, x)
-	binary.Write(bytesBuffer, binary.BigEndian, y)
-	return bytesBuffer.Bytes()
+	return p.state().Serialize()
+}
+
+func (p *Player) state() PlayerState {
+	return PlayerState{ID: p.ID, Camp: p.Camp, R: p.R, X: p.x, Y: p.y, Vx: p.Vx, Vy: p.Vy, Taken: p.taken}
 }
 
 func (p *Player) Size() uint32 {
@@ -50,72 +41,57 @@ func (p *Player) Size() uint32 {
 }
 
 func (p *Player) GetCenter() (float64, float64) {
-	return p.playerObj.X + float64(p.R), p.playerObj.Y + float64(p.R)
+	return p.x + float64(p.R), p.y + float64(p.R)
 }
 
-func (player *Player) rebound(dx, dy, rx, ry float64, cell *resolv.Object) (float64, float64) {
-	// Edge Collision
-	nx, ny := player.playerObj.X+dx, player.playerObj.Y+dy
-	rx -= dx
-	ry -= dy
-	// if nx >= cell.X && nx <= cell.X+cell.W {
-	// 	player.Vy = -player.Vy
-	// 	return rx, -ry
-	// }
-	// if ny >= cell.Y && ny <= cell.Y+cell.H {
-	// 	player.Vx = -player.Vx
-	// 	return -rx, ry
-	// }
-
-	// // Corner Collision
-	// remianV := math.Sqrt(rx*rx + ry*ry)
-	// if remianV == 0 {
-	// 	return 0, 0
-	// }
-
-	// v := math.Sqrt(player.Vx*player.Vx + player.Vy*player.Vy)
-	// player.Vx, player.Vy = v/float64(player.R)*(cell.X-nx), v/float64(player.R)*(cell.Y-ny)
-
-	// px, py := (cell.X - nx), (cell.Y - ny)
-	// pl := math.Sqrt(px*px + py*py)
-	// player.Vx *= -1
-	// player.Vy *= -1
-	// return -rx, -ry
-
-	if ny <= cell.Y-float64(2*player.R) || ny >= cell.Y+cell.H {
-		player.Vy *= -1
-		ry *= -1
-	}
-
-	if nx <= cell.X-float64(2*player.R) || nx >= cell.X+cell.W {
-		player.Vx *= -1
-		rx *= -1
+// AddPlayer queues a ball for playerID, named name for the casters, in camp. It is
+// ignored if the player already has a ball in the round, otherwise onAdded, if not nil,
+// runs on the game loop once the ball is in the arena and must not block.
+func (g *Game) AddPlayer(playerID uint64, name string, camp Camp, onAdded func()) {
+	if camp == Empty {
+		return
 	}
-	return rx, ry
+	g.submit(func() {
+		if g.addPlayer(playerID, camp) == nil {
+			return
+		}
+		g.names.Store(playerID, name)
+		if onAdded != nil {
+			onAdded()
+		}
+	})
 }
 
-func (g *Game) AddPlayer(playerID uint64, camp Camp) *Player {
-	// if g.GameStatus != GameRunning {
-	// 	return nil
-	// }
+func (g *Game) addPlayer(playerID uint64, camp Camp) *Player {
 	if camp == Empty {
 		return nil
 	}
-	g.incrCampVotes(camp)
+	if _, ok := g.players[playerID]; ok {
+		return nil
+	}
 	x, y := cellIndexToSpaceXY(camp.CenterCellIndex(mapRow, mapColumn))
 
-	ang := rand.Float64() * 2 * math.Pi
+	ang := g.rnd.Float64() * 2 * math.Pi
 	player := &Player{
 		ID:   playerID,
 		Camp: camp,
 		R:    defaultPlayerPixelR,
 		Vx:   math.Cos(ang) * playerInitialVelocity,
 		Vy:   math.Sin(ang) * playerInitialVelocity,
+		x:    x,
+		y:    y,
 	}
-	player.playerObj = resolv.NewObject(x, y, float64(2*player.R), float64(2*player.R), PlayerTag)
-	g.space.Add(player.playerObj)
-	g.Players.Store(playerID, player)
+	g.incrCampVotes(camp)
+	g.players[playerID] = player
 
-	fmt.Println("new player, camp:", camp, "x:", player.playerObj.X, "y:", player.playerObj.Y, "vx:", player.Vx, "vy:", player.Vy)
+	g.log().Debug("new player", logging.PlayerID(playerID), zap.Uint8("camp", uint8(camp)), zap.Float64("x", x), zap.Float64("y", y), zap.Float64("vx", player.Vx), zap.Float64("vy", player.Vy))
 	return player
 }
+
+// playerName returns the name of the player's ball, empty if it has none.
+func (g *Game) playerName(playerID uint64) string {
+	if v, ok := g.names.Load(playerID); ok {
+		return v.(string)
+	}
+	return ""
+}
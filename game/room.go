@@ -2,16 +2,22 @@ package game
 
 import (
 	"context"
+	"encoding/json"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/COAOX/zecrey_warrior/config"
 	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/metrics"
 	"github.com/COAOX/zecrey_warrior/model"
-	"github.com/topfreegames/pitaya/constants"
+	"github.com/COAOX/zecrey_warrior/role"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/topfreegames/pitaya/v2"
 	"github.com/topfreegames/pitaya/v2/component"
+	"github.com/topfreegames/pitaya/v2/constants"
+	"go.uber.org/zap"
 )
 
 type Room struct {
@@ -23,16 +29,19 @@ type Room struct {
 
 	tickerCancel context.CancelFunc
 	game         *Game
+	cheers       cheerBoard
+	players      sync.Map // player id -> model.Player, of the balls in the arena
 }
 
 type GameUpdate struct {
 	Data []byte `json:"data"`
 }
 
-func RegistRoom(app pitaya.Pitaya, db *db.Client, cfg *config.Config) *Game {
-	err := app.GroupCreate(context.Background(), config.GameRoomName)
-	if err != nil {
-		panic(err)
+func RegistRoom(app pitaya.Pitaya, db *db.Client, writer *db.Writer, cfg *config.Config) *Game {
+	for _, group := range []string{config.GameRoomName, config.CasterGroupName} {
+		if err := app.GroupCreate(context.Background(), group); err != nil {
+			panic(err)
+		}
 	}
 	r := &Room{
 		app: app,
@@ -40,7 +49,9 @@ func RegistRoom(app pitaya.Pitaya, db *db.Client, cfg *config.Config) *Game {
 		cfg: cfg,
 	}
 	r.ctx, r.tickerCancel = context.WithCancel(context.Background())
-	r.game = NewGame(r.ctx, cfg, db, r.onGameStart, r.onGameStop, r.onCampVotesChange)
+	r.game = NewGame(r.ctx, cfg, db, writer, r.onGameStart, r.onGameStop, r.onCampVotesChange)
+	db.OnDegradedChange(r.onDegradedChange)
+	prometheus.MustRegister(collector{r.game})
 	app.Register(r,
 		component.WithName(config.GameRoomName),
 		component.WithNameFunc(strings.ToLower),
@@ -49,33 +60,58 @@ func RegistRoom(app pitaya.Pitaya, db *db.Client, cfg *config.Config) *Game {
 }
 
 func (r *Room) AfterInit() {
-	stateChan := r.game.start()
-	go func() {
-		// ticker := time.Tick(time.Duration(33) * time.Millisecond)
-		ticker := time.NewTicker(time.Duration(1000/r.cfg.FPS) * time.Millisecond).C
-		for {
-			select {
-			case nextRoundChan := <-r.game.stopSignalChan:
-				<-nextRoundChan
-			case <-r.ctx.Done():
-				return
-			default:
-				s := <-stateChan
-				<-ticker
-				r.app.GroupBroadcast(context.Background(), r.cfg.FrontendType, config.GameRoomName, "onUpdate", GameUpdate{Data: s})
+	r.game.Start()
+	go r.broadcastFrames()
+	go r.broadcastCheers()
+}
+
+// broadcastFrames sends the latest snapshot FPS times per second, independently
+// of the tick rate. A frame that was already sent is not sent again.
+func (r *Room) broadcastFrames() {
+	fps := r.game.Config().FPS
+	ticker := time.NewTicker(time.Second / time.Duration(fps))
+	defer ticker.Stop()
+	sent := ^uint32(0)
+	for {
+		select {
+		case nextRoundChan := <-r.game.stopSignalChan:
+			<-nextRoundChan
+			// a reloaded config takes effect between rounds
+			if next := r.game.Config().FPS; next != fps {
+				fps = next
+				ticker.Reset(time.Second / time.Duration(fps))
 			}
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			s := r.game.Snapshot()
+			if s.Frame == sent {
+				continue
+			}
+			sent = s.Frame
+			data := s.Serialize()
+			metrics.BroadcastBytes.WithLabelValues("onUpdate").Observe(float64(len(data)))
+			r.app.GroupBroadcast(context.Background(), r.cfg.FrontendType, config.GameRoomName, "onUpdate", GameUpdate{Data: data})
+			r.broadcastCasterFrame(s)
 		}
-	}()
+	}
 }
 
 func (r *Room) Shutdown() {
 	r.tickerCancel()
 }
 
+// JoinRequest optionally asks for a role other than the session's, with the token it requires.
+type JoinRequest struct {
+	Role  role.Role `json:"role"`
+	Token string    `json:"token"`
+}
+
 // JoinResponse represents the result of joining room
 type JoinResponse struct {
-	Code   int    `json:"code"`
-	Result string `json:"result"`
+	Code   int       `json:"code"`
+	Result string    `json:"result"`
+	Role   role.Role `json:"role"`
 }
 
 // NewUser message will be received when new user join room
@@ -89,35 +125,71 @@ func (r *Room) Join(ctx context.Context, msg []byte) (*JoinResponse, error) {
 	// 	return nil, pitaya.Error(fmt.Errorf("GAME_NOT_START"), "GAME_NOT_START", map[string]string{"failed": "game not start"})
 	// }
 
+	var req JoinRequest
+	if len(msg) > 0 {
+		if err := json.Unmarshal(msg, &req); err != nil {
+			return nil, pitaya.Error(err, "RH-400", map[string]string{"failed": "join, invalid request"})
+		}
+	}
+
 	s := r.app.GetSessionFromCtx(ctx)
+	if req.Role != "" {
+		if err := role.Claim(r.cfg, req.Role, req.Token); err != nil {
+			return nil, pitaya.Error(err, "RH-403", map[string]string{"failed": "join, role not allowed"})
+		}
+	}
 	fakeUID := s.ID()                              // just use s.ID as uid !!!
 	err := s.Bind(ctx, strconv.Itoa(int(fakeUID))) // binding session uid
 
 	if err != nil && err != constants.ErrSessionAlreadyBound {
 		return nil, pitaya.Error(err, "RH-000", map[string]string{"failed": "bind"})
 	}
+	if req.Role != "" {
+		if err := role.Set(s, req.Role); err != nil {
+			return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "join, set role"})
+		}
+	}
+	current := role.Of(s)
 
-	// uids, err := r.app.GroupMembers(ctx, config.GameRoomName)
-	// if err != nil {
-	// 	return nil, err
-	// }
-	// s.Push("onMembers", &AllMembers{Members: uids})
-
-	// new user join group
-	r.app.GroupAddMember(ctx, config.GameRoomName, s.UID()) // add session to group
-
-	// notify others
-	r.onJoin(ctx, false)
+	// a session joining again gets the state again, but the others aren't told
+	joined, err := r.app.GroupContainsMember(ctx, config.GameRoomName, s.UID())
+	if err != nil {
+		return nil, pitaya.Error(err, "RH-500", map[string]string{"failed": "join, group issue"})
+	}
+	if !joined {
+		// the others only learn that someone arrived
+		viewers, _ := r.app.GroupCountMembers(ctx, config.GameRoomName)
+		join := ViewerJoin{UID: s.UID(), Viewers: viewers + 1}
+		if s.HasKey(config.PlayerIDKey) {
+			join.PlayerID = s.Uint64(config.PlayerIDKey)
+		}
+		r.app.GroupBroadcast(ctx, r.cfg.FrontendType, config.GameRoomName, "onViewerJoin", join)
 
-	// on session close, remove it from group
-	s.OnClose(func() {
-		r.app.GroupRemoveMember(ctx, config.GameRoomName, s.UID())
-	})
+		r.app.GroupAddMember(ctx, config.GameRoomName, s.UID()) // add session to group
+		// on session close, remove it from group
+		s.OnClose(func() {
+			r.app.GroupRemoveMember(ctx, config.GameRoomName, s.UID())
+		})
+	}
+	if current.Can(role.CasterFeed) {
+		if casting, _ := r.app.GroupContainsMember(ctx, config.CasterGroupName, s.UID()); !casting {
+			r.app.GroupAddMember(ctx, config.CasterGroupName, s.UID())
+			s.OnClose(func() {
+				r.app.GroupRemoveMember(ctx, config.CasterGroupName, s.UID())
+			})
+		}
+	}
 
-	return &JoinResponse{Result: "success"}, nil
+	// the newcomer alone gets the whole state
+	if err := s.Push("onJoin", r.mapInfo(false)); err != nil {
+		r.game.log().Error("push map info failed", zap.String("uid", s.UID()), zap.Error(err))
+	}
+	return &JoinResponse{Result: "success", Role: current}, nil
 }
 
-func (r *Room) onJoin(ctx context.Context, replay bool) {
+// mapInfo describes the map and the state of the round as of the last frame.
+func (r *Room) mapInfo(replay bool) MapInfo {
+	snap := r.game.Snapshot()
 	mi := MapInfo{
 		Row:        mapRow,
 		Column:     mapColumn,
@@ -126,22 +198,56 @@ func (r *Room) onJoin(ctx context.Context, replay bool) {
 
 		Item:   AllItems,
 		Replay: replay,
+
+		GameID: r.game.GetGameID(),
+		Status: snap.Status,
+		Frame:  snap.Serialize(),
+	}
+	if snap.Remaining > 0 {
+		mi.Remaining = int64(snap.Remaining.Seconds())
 	}
 
 	pids := []uint64{}
-	r.game.Players.Range(func(key, value interface{}) bool {
-		pids = append(pids, key.(uint64))
-		return true
-	})
+	for _, p := range snap.Players {
+		pids = append(pids, p.ID)
+	}
+	mi.Players = r.listPlayers(pids)
+	return mi
+}
 
-	mi.Players, _ = r.db.Player.List(pids...)
-	r.app.GroupBroadcast(ctx, r.cfg.FrontendType, config.GameRoomName, "onJoin", mi)
+// listPlayers returns the players pids, from the cache or, for those missing, from the database.
+func (r *Room) listPlayers(pids []uint64) []model.Player {
+	players := make([]model.Player, 0, len(pids))
+	missing := []uint64{}
+	for _, id := range pids {
+		if v, ok := r.players.Load(id); ok {
+			players = append(players, v.(model.Player))
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 || r.db.Degraded() {
+		return players
+	}
+	loaded, err := r.db.Player.List(missing...)
+	if err != nil {
+		r.game.log().Error("list players failed", zap.Error(err))
+	}
+	for _, p := range loaded {
+		r.players.Store(p.PlayerID, p)
+	}
+	return append(players, loaded...)
 }
 
 func (r *Room) onGameStart(ctx context.Context) {
 	info, _ := r.game.GetGameInfo()
 	r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.ChatRoomName, "onGameStart", info)
-	r.onJoin(ctx, true)
+	// a new round is a new map for everyone
+	r.players.Range(func(key, value interface{}) bool {
+		r.players.Delete(key)
+		return true
+	})
+	r.app.GroupBroadcast(ctx, r.cfg.FrontendType, config.GameRoomName, "onJoin", r.mapInfo(true))
 }
 
 func (r *Room) onGameStop(ctx context.Context) {
@@ -157,6 +263,60 @@ func (r *Room) onCampVotesChange(camp Camp, votes int32) {
 	})
 }
 
+// Cheer sends a viewer reaction to a camp
+func (r *Room) Cheer(ctx context.Context, req *CheerRequest) (*CheerResponse, error) {
+	camp := req.Camp
+	if _, ok := CampTagMap[camp]; !ok || camp == Empty {
+		return nil, pitaya.Error(errInvalidCamp, "RH-400", map[string]string{"failed": "cheer, unknown camp"})
+	}
+	reaction := req.Reaction
+	if reaction == "" {
+		reaction = defaultReaction
+	}
+	if len(reaction) > maxReactionLen {
+		return nil, pitaya.Error(errInvalidReaction, "RH-400", map[string]string{"failed": "cheer, reaction too long"})
+	}
+
+	s := r.app.GetSessionFromCtx(ctx)
+	if !role.Of(s).Can(role.Cheer) {
+		return nil, pitaya.Error(role.ErrForbidden, "RH-403", map[string]string{"failed": "cheer, role not allowed"})
+	}
+	now := time.Now().UnixMilli()
+	if last := s.Int64(lastCheerKey); now-last < cheerCooldown.Milliseconds() {
+		return nil, pitaya.Error(errCheerTooFast, "RH-429", map[string]string{"failed": "cheer, too many reactions"})
+	}
+	s.Set(lastCheerKey, now)
+
+	r.cheers.add(camp, reaction)
+	return &CheerResponse{Result: "success", Cheers: r.game.Cheer(camp)}, nil
+}
+
+// broadcastCheers periodically pushes the aggregated cheers to the game and chat rooms
+func (r *Room) broadcastCheers() {
+	ticker := time.NewTicker(cheerBroadcastInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+			reactions, changed := r.cheers.flush()
+			if !changed {
+				continue
+			}
+			c := CampCheers{Cheers: r.game.CampCheers(), Reactions: reactions}
+			r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.GameRoomName, "onCheer", c)
+			r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.ChatRoomName, "onCheer", c)
+		}
+	}
+}
+
+func (r *Room) onDegradedChange(degraded bool) {
+	d := Degraded{Degraded: degraded}
+	r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.GameRoomName, "onDegraded", d)
+	r.app.GroupBroadcast(r.ctx, r.cfg.FrontendType, config.ChatRoomName, "onDegraded", d)
+}
+
 // TODO
 type MapInfo struct {
 	Row    uint32 `json:"row"`
@@ -168,6 +328,30 @@ type MapInfo struct {
 	Item    []Item         `json:"items"`
 	Players []model.Player `json:"players"`
 	Replay  bool           `json:"replay"`
+
+	GameID    uint       `json:"game_id"`
+	Status    GameStatus `json:"status"`
+	Remaining int64      `json:"remaining"` // seconds
+	Frame     []byte     `json:"frame"`     // the last frame, as in onUpdate
+}
+
+// ViewerJoin is broadcast, as onViewerJoin, to the game room when a session joins it.
+type ViewerJoin struct {
+	UID      string `json:"uid"`
+	PlayerID uint64 `json:"player_id,omitempty"` // if the session joined the chat first
+	Viewers  int    `json:"viewers"`
+}
+
+// Degraded is pushed when the database becomes unavailable, rankings and history
+// may then be stale, or available again.
+type Degraded struct {
+	Degraded bool `json:"degraded"`
+}
+
+// Maintenance is pushed, as onMaintenance, before the server shuts down.
+type Maintenance struct {
+	Message  string `json:"message"`
+	Deadline int64  `json:"deadline"` // unix seconds, when clients are disconnected
 }
 
 type CampVotesChange struct {
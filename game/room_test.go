@@ -0,0 +1,112 @@
+package game
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/model"
+	"github.com/topfreegames/pitaya/v2/networkentity"
+	"github.com/topfreegames/pitaya/v2/protos"
+	"github.com/topfreegames/pitaya/v2/session"
+)
+
+// testEntity records what is pushed to its session.
+type testEntity struct {
+	networkentity.NetworkEntity
+	pushes map[string]interface{}
+}
+
+func (e *testEntity) Push(route string, v interface{}) error {
+	e.pushes[route] = v
+	return nil
+}
+
+func (e *testEntity) RemoteAddr() net.Addr { return nil }
+
+func (e *testEntity) SendRequest(context.Context, string, string, interface{}) (*protos.Response, error) {
+	return nil, nil
+}
+
+// groupApp is a testApp with a game room group.
+type groupApp struct {
+	testApp
+	members map[string]bool
+}
+
+func (a *groupApp) GroupContainsMember(_ context.Context, _, uid string) (bool, error) {
+	return a.members[uid], nil
+}
+
+func (a *groupApp) GroupCountMembers(context.Context, string) (int, error) {
+	return len(a.members), nil
+}
+
+func (a *groupApp) GroupAddMember(_ context.Context, _, uid string) error {
+	a.members[uid] = true
+	return nil
+}
+
+func TestLateJoin(t *testing.T) {
+	cfg := &config.Config{FPS: 30, ItemFrameChance: 1 << 30, GameDuration: 600, GameRoundInterval: 60, FrontendType: "test"}
+	d := db.NewMemoryClient()
+	for _, p := range []model.Player{{PlayerID: 1, Name: "alice"}, {PlayerID: 2, Name: "bob"}} {
+		p := p
+		if err := d.Player.Create(&p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g := NewGame(ctx, cfg, d, db.NewWriter(d, db.WriterConfig{}), func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
+	g.Start()
+	g.AddPlayer(1, "alice", BTC, nil)
+	g.AddPlayer(2, "bob", ETH, nil)
+	// mid-round: the balls are in and have moved
+	deadline := time.Now().Add(3 * time.Second)
+	for s := g.Snapshot(); len(s.Players) < 2 || s.Frame < 10; s = g.Snapshot() {
+		if time.Now().After(deadline) {
+			t.Fatal("the round didn't run")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	joinedAt := g.Snapshot().Frame
+
+	entity := &testEntity{pushes: map[string]interface{}{}}
+	app := &groupApp{testApp: testApp{s: session.NewSessionPool().NewSession(entity, true)}, members: map[string]bool{"viewer": true}}
+	r := &Room{game: g, app: app, db: d, cfg: cfg}
+	if _, err := r.Join(ctx, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(app.routes) != 1 || app.routes[0] != "onViewerJoin" {
+		t.Errorf("broadcasts %v, the others are only told someone joined", app.routes)
+	}
+	mi, ok := entity.pushes["onJoin"].(MapInfo)
+	if !ok {
+		t.Fatalf("pushed %v, want the map info on onJoin", entity.pushes)
+	}
+	if mi.Replay || mi.Status != GameRunning || mi.GameID == 0 || mi.GameID != g.GetGameID() {
+		t.Errorf("map info replay %v status %v game %d, want the running round %d", mi.Replay, mi.Status, mi.GameID, g.GetGameID())
+	}
+	if mi.Remaining <= 0 || mi.Remaining > int64(cfg.GameDuration) {
+		t.Errorf("remaining %d, want the round's time left", mi.Remaining)
+	}
+	if len(mi.Frame) < 4 {
+		t.Fatalf("frame of %d bytes", len(mi.Frame))
+	}
+	if frame := binary.BigEndian.Uint32(mi.Frame); frame < joinedAt {
+		t.Errorf("frame %d, want the current frame, at least %d", frame, joinedAt)
+	}
+	names := map[uint64]string{}
+	for _, p := range mi.Players {
+		names[p.PlayerID] = p.Name
+	}
+	if len(names) != 2 || names[1] != "alice" || names[2] != "bob" {
+		t.Errorf("players %v, want the balls of the round, loaded from the database", names)
+	}
+}
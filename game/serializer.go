@@ -14,12 +14,11 @@ func NewSerializer() *Serializer {
 
 // Marshal returns the JSON encoding of v.
 func (s *Serializer) Marshal(v interface{}) ([]byte, error) {
-	if g, ok := v.(*Game); ok {
-		return g.Serialize()
-	}
 	switch v := v.(type) {
 	case *Game:
 		return v.Serialize()
+	case *Snapshot:
+		return v.Serialize(), nil
 	case *Player:
 		return v.Serialize(), nil
 	case []byte:
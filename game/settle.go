@@ -0,0 +1,118 @@
+package game
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/db"
+	"go.uber.org/zap"
+)
+
+const (
+	settleRetryInterval = 30 * time.Second
+	// settleGrace leaves time to the game loop to settle a round before the reconciler picks it up
+	settleGrace          = time.Minute
+	settleReconcileBatch = 100
+	// settleFlushTimeout bounds the wait for the queued votes before settling
+	settleFlushTimeout = 5 * time.Second
+)
+
+var (
+	errGameNotCreated = errors.New("game row not created yet")
+	errDegraded       = errors.New("database unavailable")
+)
+
+type settlement struct {
+	game    db.GameRef
+	winner  Camp
+	endTime time.Time
+}
+
+// settler retries the settlements that failed and reconciles the rounds that
+// ended with a winner but were never settled, e.g. because the process died.
+type settler struct {
+	db     *db.Client
+	writer *db.Writer
+
+	mu      sync.Mutex
+	pending []settlement
+}
+
+func newSettler(db *db.Client, writer *db.Writer) *settler {
+	return &settler{db: db, writer: writer}
+}
+
+// settle settles the round, on failure it is retried in the background.
+// A round whose game row is not created yet waits for it, and so does a
+// round whose votes are still queued in the writer: they must be credited.
+func (s *settler) settle(st settlement) bool {
+	gameID := st.game()
+	var err error
+	switch {
+	case gameID == 0:
+		err = errGameNotCreated
+	case s.db.Degraded():
+		// the game loop settles too, it must not wait on a database known to be down
+		err = errDegraded
+	default:
+		ctx, cancel := context.WithTimeout(context.Background(), settleFlushTimeout)
+		err = s.writer.Flush(ctx)
+		cancel()
+	}
+	if err == nil {
+		err = s.db.Game.Settle(gameID, uint8(st.winner), st.endTime)
+	}
+	if err == nil || err == db.ErrAlreadySettled {
+		return true
+	}
+	roundLog(gameID).Error("failed to settle game, will retry", zap.Error(err))
+	s.mu.Lock()
+	s.pending = append(s.pending, st)
+	s.mu.Unlock()
+	return false
+}
+
+func (s *settler) run(ctx context.Context, currentGameID func() uint) {
+	ticker := time.NewTicker(settleRetryInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.retry()
+			s.reconcile(currentGameID())
+		}
+	}
+}
+
+func (s *settler) retry() {
+	s.mu.Lock()
+	pending := s.pending
+	s.pending = nil
+	s.mu.Unlock()
+	for _, st := range pending {
+		if s.settle(st) {
+			roundLog(st.game()).Info("game settled on retry")
+		}
+	}
+}
+
+func (s *settler) reconcile(currentGameID uint) {
+	games, err := s.db.Game.ListUnsettled(time.Now().Add(-settleGrace), settleReconcileBatch)
+	if err != nil {
+		roomLog().Error("failed to list unsettled games", zap.Error(err))
+		return
+	}
+	for _, g := range games {
+		if g.ID == currentGameID {
+			continue
+		}
+		id := g.ID
+		if s.settle(settlement{game: func() uint { return id }, winner: Camp(g.WinnerID), endTime: g.EndTime}) {
+			roundLog(g.ID).Info("unsettled game reconciled", zap.Uint8("winner", g.WinnerID))
+		}
+	}
+}
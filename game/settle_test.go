@@ -0,0 +1,193 @@
+package game
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/model"
+)
+
+// newSettleTest returns a database with a round that player 1 voted BTC in and player 2 ETH.
+func newSettleTest(t *testing.T, end time.Time) (*db.Client, *model.Game) {
+	d := db.NewMemoryClient()
+	gm := &model.Game{StartTime: end.Add(-time.Minute), EndTime: end}
+	if err := d.Game.Create(gm); err != nil {
+		t.Fatal(err)
+	}
+	for id, camp := range map[uint64]Camp{1: BTC, 2: ETH} {
+		if err := d.Player.Create(&model.Player{PlayerID: id}); err != nil {
+			t.Fatal(err)
+		}
+		if err := d.Player.AddVote(&model.PlayerVote{GameID: gm.ID, PlayerID: id, Camp: uint8(camp)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return d, gm
+}
+
+func scores(t *testing.T, d *db.Client) (btc int, p1, p2 int) {
+	camps, err := d.Camp.ListRank(len(model.Camps))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range camps {
+		if c.ID == uint8(BTC) {
+			btc = c.Score
+		}
+	}
+	players, err := d.Player.List(1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range players {
+		switch p.PlayerID {
+		case 1:
+			p1 = p.Score
+		case 2:
+			p2 = p.Score
+		}
+	}
+	return btc, p1, p2
+}
+
+func TestSettleTwice(t *testing.T) {
+	d, gm := newSettleTest(t, time.Now())
+	s := newSettler(d, db.NewWriter(d, db.WriterConfig{}))
+	st := settlement{game: func() uint { return gm.ID }, winner: BTC, endTime: gm.EndTime}
+	btc, _, _ := scores(t, d)
+
+	for i := 0; i < 3; i++ {
+		if !s.settle(st) {
+			t.Fatalf("settle %d failed", i)
+		}
+	}
+	if len(s.pending) != 0 {
+		t.Errorf("%d settlements pending, want none", len(s.pending))
+	}
+	gotBTC, p1, p2 := scores(t, d)
+	if gotBTC != btc+1 || p1 != 1 || p2 != 0 {
+		t.Errorf("btc %d, player 1 %d, player 2 %d after settling three times, want %d 1 0", gotBTC, p1, p2, btc+1)
+	}
+	if g, _ := d.Game.Get(gm.ID); !g.Settled || g.WinnerID != uint8(BTC) {
+		t.Errorf("game settled=%v winner=%d", g.Settled, g.WinnerID)
+	}
+}
+
+func TestSettleRetry(t *testing.T) {
+	d, gm := newSettleTest(t, time.Now())
+	s := newSettler(d, db.NewWriter(d, db.WriterConfig{}))
+	// the game row isn't created yet, the writer sets its id later
+	var id uint
+	if s.settle(settlement{game: func() uint { return id }, winner: BTC, endTime: gm.EndTime}) {
+		t.Fatal("a round without a game row was settled")
+	}
+	if len(s.pending) != 1 {
+		t.Fatalf("%d settlements pending, want 1", len(s.pending))
+	}
+	s.retry()
+	if len(s.pending) != 1 {
+		t.Fatalf("%d settlements pending after a failed retry, want 1", len(s.pending))
+	}
+
+	id = gm.ID
+	s.retry()
+	if len(s.pending) != 0 {
+		t.Errorf("%d settlements pending after the retry, want none", len(s.pending))
+	}
+	if _, p1, _ := scores(t, d); p1 != 1 {
+		t.Errorf("player 1 score %d, want 1", p1)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	d, ended := newSettleTest(t, time.Now().Add(-2*settleGrace))
+	ended.WinnerID = uint8(BTC)
+	if err := d.Game.Update(ended); err != nil {
+		t.Fatal(err)
+	}
+	// the current round and a round within the grace period are left to the game loop
+	current := &model.Game{StartTime: time.Now().Add(-3 * settleGrace), EndTime: time.Now().Add(-2 * settleGrace), WinnerID: uint8(ETH)}
+	recent := &model.Game{StartTime: time.Now().Add(-time.Minute), EndTime: time.Now(), WinnerID: uint8(ETH)}
+	for _, gm := range []*model.Game{current, recent} {
+		if err := d.Game.Create(gm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := newSettler(d, db.NewWriter(d, db.WriterConfig{}))
+	s.reconcile(current.ID)
+	for _, tt := range []struct {
+		id      uint
+		settled bool
+	}{{ended.ID, true}, {current.ID, false}, {recent.ID, false}} {
+		if g, _ := d.Game.Get(tt.id); g.Settled != tt.settled {
+			t.Errorf("game %d settled=%v, want %v", tt.id, g.Settled, tt.settled)
+		}
+	}
+
+	// a second pass finds nothing left to settle
+	btc, p1, _ := scores(t, d)
+	s.reconcile(current.ID)
+	if gotBTC, gotP1, _ := scores(t, d); gotBTC != btc || gotP1 != p1 {
+		t.Errorf("scores changed on the second pass: btc %d->%d, player 1 %d->%d", btc, gotBTC, p1, gotP1)
+	}
+}
+
+func TestSettleQueuedVotes(t *testing.T) {
+	d := db.NewMemoryClient()
+	gm := &model.Game{StartTime: time.Now().Add(-time.Minute), EndTime: time.Now()}
+	if err := d.Game.Create(gm); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Player.Create(&model.Player{PlayerID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	// the vote waits in the queue longer than the round takes to end
+	writer := db.NewWriter(d, db.WriterConfig{FlushInterval: int(time.Minute / time.Millisecond)})
+	ref := func() uint { return gm.ID }
+	writer.AddVote(ref, model.PlayerVote{PlayerID: 1, Camp: uint8(BTC)})
+
+	if !newSettler(d, writer).settle(settlement{game: ref, winner: BTC, endTime: gm.EndTime}) {
+		t.Fatal("settle failed")
+	}
+	if _, p1, _ := scores(t, d); p1 != 1 {
+		t.Errorf("player 1 score %d, the queued vote wasn't counted", p1)
+	}
+	if n := d.Player.GetWinnerVotes(gm.ID, uint8(BTC)); n != 1 {
+		t.Errorf("%d winner votes, want 1", n)
+	}
+}
+
+func TestEndRoundCountsQueuedVotes(t *testing.T) {
+	cfg := &config.Config{FPS: 30, ItemFrameChance: 1 << 30, GameDuration: 600, GameRoundInterval: 60, FrontendType: "test"}
+	d := db.NewMemoryClient()
+	if err := d.Player.Create(&model.Player{PlayerID: 1}); err != nil {
+		t.Fatal(err)
+	}
+	writer := db.NewWriter(d, db.WriterConfig{FlushInterval: int(time.Minute / time.Millisecond)})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	g := NewGame(ctx, cfg, d, writer, func(context.Context) {}, func(context.Context) {}, func(camp Camp, votes int32) {})
+	paint(g, BTC, 0, 0, mapColumn-1, mapRow-1)
+	g.Start()
+
+	// a chat vote, as chat.Room.deliver casts it
+	voted := make(chan struct{})
+	g.AddPlayer(1, "alice", BTC, func() {
+		writer.AddVote(g.GameRef(), model.PlayerVote{PlayerID: 1, Camp: uint8(BTC)})
+		close(voted)
+	})
+	<-voted
+	if err := g.EndRound(); err != nil {
+		t.Fatal(err)
+	}
+	if _, p1, _ := scores(t, d); p1 != 1 {
+		t.Errorf("player 1 score %d after the round, the queued vote wasn't counted", p1)
+	}
+	if stop := g.GetGameStop(); stop.Winner != BTC || stop.WinnerVotes != 1 {
+		t.Errorf("game stop %+v, want BTC with 1 vote", stop)
+	}
+}
@@ -0,0 +1,124 @@
+package game
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sort"
+	"time"
+)
+
+// Snapshot is the state of the game the loop publishes after every frame, for
+// serialization and for readers outside the loop. It is never modified once
+// published, readers must not modify it either.
+type Snapshot struct {
+	Frame     uint32         `json:"frame"`
+	Status    GameStatus     `json:"status"`
+	Remaining time.Duration  `json:"remaining"` // round time left
+	Cells     []Camp         `json:"cells"`
+	Players   []PlayerState  `json:"players"` // in id order
+	Items     []ItemState    `json:"items"`   // in id order
+	CampVotes map[Camp]int32 `json:"camp_votes"`
+}
+
+// PlayerState and ItemState positions are the top left corner in space coordinates.
+type PlayerState struct {
+	ID   uint64  `json:"id"`
+	Camp Camp    `json:"camp"`
+	R    int     `json:"r"`
+	X    float64 `json:"x"`
+	Y    float64 `json:"y"`
+	Vx   float64 `json:"vx"`
+	Vy   float64 `json:"vy"`
+
+	Taken int `json:"taken"` // cells converted this round, not in the frames
+}
+
+type ItemState struct {
+	ID   uint32   `json:"id"`
+	Type ItemType `json:"type"`
+	X    float64  `json:"x"`
+	Y    float64  `json:"y"`
+}
+
+// snapshot copies the state of the game, it runs on the game loop.
+func (g *Game) snapshot() *Snapshot {
+	s := &Snapshot{
+		Frame:     g.frameNumber,
+		Status:    g.GameStatus,
+		Remaining: g.remaining(),
+		Cells:     append([]Camp(nil), g.Map.Cells...),
+		Players:   make([]PlayerState, 0, len(g.players)),
+		Items:     make([]ItemState, 0, len(g.items)),
+		CampVotes: make(map[Camp]int32, len(g.campVotes)),
+	}
+	for _, p := range g.players {
+		s.Players = append(s.Players, p.state())
+	}
+	sort.Slice(s.Players, func(i, j int) bool { return s.Players[i].ID < s.Players[j].ID })
+	for _, i := range g.items {
+		s.Items = append(s.Items, i.state())
+	}
+	sort.Slice(s.Items, func(i, j int) bool { return s.Items[i].ID < s.Items[j].ID })
+	for camp, votes := range g.campVotes {
+		s.CampVotes[camp] = votes
+	}
+	return s
+}
+
+// publish makes the current state visible to the readers of Snapshot.
+func (g *Game) publish() {
+	g.published.Store(g.snapshot())
+}
+
+// Snapshot returns the state published after the last frame.
+func (g *Game) Snapshot() *Snapshot {
+	return g.published.Load().(*Snapshot)
+}
+
+// frame number: 4 bytes
+// map size: 4 bytes
+// map: map size bytes
+// player number: 4 bytes
+// players: 26 * len(players) bytes
+// item number: 4 bytes
+// items: 21 * items number bytes
+func (s *Snapshot) Serialize() []byte {
+	m := Map{Cells: s.Cells}
+	buf := bytes.NewBuffer(make([]byte, 0, 16+int(m.Size())+26*len(s.Players)+21*len(s.Items)))
+	binary.Write(buf, binary.BigEndian, s.Frame)
+	binary.Write(buf, binary.BigEndian, m.Size())
+	buf.Write(m.Serialize())
+	binary.Write(buf, binary.BigEndian, uint32(len(s.Players)))
+	for _, p := range s.Players {
+		buf.Write(p.Serialize())
+	}
+	binary.Write(buf, binary.BigEndian, uint32(len(s.Items)))
+	for _, i := range s.Items {
+		buf.Write(i.Serialize())
+	}
+	return buf.Bytes()
+}
+
+// ID 8 byte
+// R 2 byte
+// X 8 byte
+// Y 8 byte
+func (p PlayerState) Serialize() []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 26))
+	binary.Write(buf, binary.BigEndian, p.ID)
+	binary.Write(buf, binary.BigEndian, uint16(p.R))
+	x, y := space2MapXY(p.X+float64(p.R), p.Y+float64(p.R))
+	binary.Write(buf, binary.BigEndian, x)
+	binary.Write(buf, binary.BigEndian, y)
+	return buf.Bytes()
+}
+
+func (i ItemState) Serialize() []byte {
+	buf := bytes.NewBuffer(make([]byte, 0, 21))
+	binary.Write(buf, binary.BigEndian, i.ID)
+	binary.Write(buf, binary.BigEndian, uint8(i.Type))
+	x, y := space2MapXY(i.X+float64(itemPixelR), i.Y+float64(itemPixelR))
+	binary.Write(buf, binary.BigEndian, x)
+	binary.Write(buf, binary.BigEndian, y)
+	return buf.Bytes()
+}
@@ -0,0 +1,137 @@
+package game
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/metrics"
+)
+
+const (
+	// maxCatchUpTicks is how far behind schedule, in ticks, the loop may fall and still
+	// run the missed ticks back to back. Further behind, it drops them and starts over.
+	maxCatchUpTicks = 5
+
+	// tunedTickRate is the tick rate ball velocities and item chances are expressed at,
+	// they are scaled to the configured rate so that the game plays the same at any rate.
+	tunedTickRate = 30
+)
+
+// TickStats describes how well the game loop keeps up with its tick rate.
+type TickStats struct {
+	Rate         int           `json:"rate"`
+	Ticks        uint64        `json:"ticks"`
+	Overruns     uint64        `json:"overruns"` // ticks that took longer than the tick interval
+	Skipped      uint64        `json:"skipped"`  // ticks dropped because the loop was too far behind
+	LastDuration time.Duration `json:"last_duration"`
+	MaxDuration  time.Duration `json:"max_duration"`
+	Lag          time.Duration `json:"lag"` // how late the last tick started
+	LastTick     time.Time     `json:"last_tick"`
+}
+
+type tickStats struct {
+	ticks, overruns, skipped uint64
+	last, max, lag, at       int64
+}
+
+func (s *tickStats) record(start time.Time, d, interval, lag time.Duration) {
+	atomic.AddUint64(&s.ticks, 1)
+	if d > interval {
+		atomic.AddUint64(&s.overruns, 1)
+	}
+	atomic.StoreInt64(&s.last, int64(d))
+	if int64(d) > atomic.LoadInt64(&s.max) {
+		atomic.StoreInt64(&s.max, int64(d))
+	}
+	atomic.StoreInt64(&s.lag, int64(lag))
+	atomic.StoreInt64(&s.at, start.UnixNano())
+}
+
+// TickStats returns the statistics of the game loop since the server started.
+func (g *Game) TickStats() TickStats {
+	s := TickStats{
+		Rate:         g.tickRate,
+		Ticks:        atomic.LoadUint64(&g.ticks.ticks),
+		Overruns:     atomic.LoadUint64(&g.ticks.overruns),
+		Skipped:      atomic.LoadUint64(&g.ticks.skipped),
+		LastDuration: time.Duration(atomic.LoadInt64(&g.ticks.last)),
+		MaxDuration:  time.Duration(atomic.LoadInt64(&g.ticks.max)),
+		Lag:          time.Duration(atomic.LoadInt64(&g.ticks.lag)),
+	}
+	if at := atomic.LoadInt64(&g.ticks.at); at > 0 {
+		s.LastTick = time.Unix(0, at)
+	}
+	return s
+}
+
+// run steps the game every tick interval until ctx is done. A late tick is followed
+// by the next one right away until the loop is back on schedule, unless it is more
+// than maxCatchUpTicks behind. The loop doesn't tick during the break between rounds,
+// it only runs the control calls: the commands wait for the next round.
+func (g *Game) run() {
+	interval := time.Second / time.Duration(g.tickRate)
+
+	var checkpointC <-chan time.Time
+	if g.Config().CheckpointInterval > 0 {
+		ticker := time.NewTicker(time.Duration(g.Config().CheckpointInterval) * time.Second)
+		defer ticker.Stop()
+		checkpointC = ticker.C
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	<-timer.C
+
+	next := time.Now()
+	for {
+		if g.GameStatus == GameStopped {
+		brk:
+			for {
+				select {
+				case <-g.ctx.Done():
+					return
+				case f := <-g.controls:
+					f()
+				case <-g.roundTimer.C:
+					break brk
+				}
+			}
+			g.startRound()
+			// the break between rounds is not lag
+			next = time.Now()
+		}
+
+		start := time.Now()
+		g.Step()
+		d := time.Since(start)
+		g.ticks.record(start, d, interval, start.Sub(next))
+		metrics.TickDuration.Observe(d.Seconds())
+		if g.GameStatus == GameStopped {
+			// EndRound ran in this tick
+			continue
+		}
+
+		next = next.Add(interval)
+		if behind := time.Since(next); behind > maxCatchUpTicks*interval {
+			atomic.AddUint64(&g.ticks.skipped, uint64(behind/interval))
+			next = time.Now()
+		}
+		timer.Reset(time.Until(next))
+	wait:
+		for {
+			select {
+			case <-g.ctx.Done():
+				return
+			case <-g.roundTimer.C:
+				g.endRound()
+				if !timer.Stop() {
+					<-timer.C
+				}
+				break wait
+			case <-checkpointC:
+				g.checkpoint()
+			case <-timer.C:
+				break wait
+			}
+		}
+	}
+}
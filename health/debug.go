@@ -0,0 +1,117 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"runtime/pprof"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/game"
+)
+
+const (
+	defaultProfileDuration = 30 * time.Second
+	defaultTraceDuration   = time.Second
+	maxProfileDuration     = 2 * time.Minute
+)
+
+// State is the dump of /debug/state.
+type State struct {
+	Status
+	Snapshot *game.Snapshot `json:"snapshot"`
+}
+
+// debugRoutes registers the profiles and the state dump under /debug/, they require
+// the admin token as "Authorization: Bearer <token>". net/http/pprof is not used:
+// importing it serves the profiles, unauthenticated, on http.DefaultServeMux, which
+// pitaya exposes on its metrics port.
+func (c *Checker) debugRoutes(mux *http.ServeMux) {
+	mux.Handle("/debug/pprof/", c.authenticate(http.HandlerFunc(profile)))
+	mux.Handle("/debug/state", c.authenticate(http.HandlerFunc(c.state)))
+}
+
+func (c *Checker) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !c.authorize(token) {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"result": "unauthorized"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// state dumps the health of the server and the last published frame, cells, balls,
+// items and votes, without going through the game loop: it works on a stuck loop too.
+func (c *Checker) state(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, State{Status: c.Status(), Snapshot: c.game.Snapshot()})
+}
+
+// profile serves /debug/pprof/<name> as net/http/pprof does: a CPU profile or an
+// execution trace over ?seconds, or a runtime profile such as heap or goroutine,
+// in text with ?debug=1. The bare path lists the runtime profiles.
+func profile(w http.ResponseWriter, r *http.Request) {
+	name := strings.TrimPrefix(r.URL.Path, "/debug/pprof/")
+	switch name {
+	case "":
+		profiles := map[string]int{}
+		for _, p := range pprof.Profiles() {
+			profiles[p.Name()] = p.Count()
+		}
+		writeJSON(w, http.StatusOK, profiles)
+	case "profile":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := pprof.StartCPUProfile(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sleep(r.Context(), duration(r, defaultProfileDuration))
+		pprof.StopCPUProfile()
+	case "trace":
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if err := trace.Start(w); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		sleep(r.Context(), duration(r, defaultTraceDuration))
+		trace.Stop()
+	default:
+		p := pprof.Lookup(name)
+		if p == nil {
+			http.Error(w, "unknown profile", http.StatusNotFound)
+			return
+		}
+		debug, _ := strconv.Atoi(r.FormValue("debug"))
+		if debug > 0 {
+			w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		} else {
+			w.Header().Set("Content-Type", "application/octet-stream")
+		}
+		p.WriteTo(w, debug)
+	}
+}
+
+// duration returns the ?seconds of r, up to maxProfileDuration, or def.
+func duration(r *http.Request, def time.Duration) time.Duration {
+	seconds, err := strconv.ParseFloat(r.FormValue("seconds"), 64)
+	if err != nil || seconds <= 0 {
+		return def
+	}
+	if d := time.Duration(seconds * float64(time.Second)); d < maxProfileDuration {
+		return d
+	}
+	return maxProfileDuration
+}
+
+// sleep waits for d, or for the client to go away.
+func sleep(ctx context.Context, d time.Duration) {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+	case <-ctx.Done():
+	}
+}
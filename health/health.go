@@ -0,0 +1,139 @@
+package health
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/game"
+)
+
+const (
+	StatusOK       = "ok"
+	StatusDegraded = "degraded"
+	StatusStarting = "starting" // the game loop hasn't ticked yet
+	StatusStalled  = "stalled"  // the game loop stopped ticking during a round
+	StatusDraining = "draining" // the server is shutting down
+
+	// stallTimeout is how long the game loop may go without ticking, during a round,
+	// before it is reported stalled. A tick lasts a few milliseconds at most.
+	stallTimeout = 5 * time.Second
+)
+
+type Status struct {
+	Status   string         `json:"status"`
+	Database string         `json:"database"`
+	Writer   db.WriterStats `json:"writer"`
+	Tick     game.TickStats `json:"tick"`
+	Round    Round          `json:"round"`
+}
+
+// Round is the state of the round as of the last frame.
+type Round struct {
+	GameID    uint            `json:"game_id"`
+	Status    game.GameStatus `json:"status"`
+	Frame     uint32          `json:"frame"`
+	Remaining int64           `json:"remaining"` // seconds
+	Players   int             `json:"players"`
+	Items     int             `json:"items"`
+}
+
+// Checker reports the health of the server over HTTP. A degraded server is still
+// healthy and ready: it keeps the game running while the database is unavailable.
+// A stalled game loop is neither, a server starting or draining is not ready.
+type Checker struct {
+	db        *db.Client
+	writer    *db.Writer
+	game      gameState
+	authorize func(token string) bool
+	draining  int32
+}
+
+// gameState is what the checker reads of the game, without going through its loop.
+type gameState interface {
+	GetGameID() uint
+	Snapshot() *game.Snapshot
+	TickStats() game.TickStats
+}
+
+// New returns a checker of the server, authorize tells whether a bearer token
+// gives access to the debug endpoints.
+func New(db *db.Client, writer *db.Writer, game *game.Game, authorize func(token string) bool) *Checker {
+	return &Checker{db: db, writer: writer, game: game, authorize: authorize}
+}
+
+func (c *Checker) Routes(mux *http.ServeMux) {
+	mux.HandleFunc("/healthz", c.healthz)
+	mux.HandleFunc("/readyz", c.readyz)
+	c.debugRoutes(mux)
+}
+
+// Drain marks the server as shutting down, it isn't ready any more.
+func (c *Checker) Drain() {
+	atomic.StoreInt32(&c.draining, 1)
+}
+
+func (c *Checker) Status() Status {
+	s := Status{Status: StatusOK, Database: "up", Writer: c.writer.Stats(), Tick: c.game.TickStats()}
+	snap := c.game.Snapshot()
+	s.Round = Round{
+		GameID:  c.game.GetGameID(),
+		Status:  snap.Status,
+		Frame:   snap.Frame,
+		Players: len(snap.Players),
+		Items:   len(snap.Items),
+	}
+	if snap.Remaining > 0 {
+		s.Round.Remaining = int64(snap.Remaining.Seconds())
+	}
+	if c.db.Degraded() {
+		s.Status, s.Database = StatusDegraded, "down"
+	}
+	switch {
+	case c.stalled(s):
+		s.Status = StatusStalled
+	case s.Tick.LastTick.IsZero():
+		s.Status = StatusStarting
+	case atomic.LoadInt32(&c.draining) == 1:
+		s.Status = StatusDraining
+	}
+	return s
+}
+
+// stalled tells whether the loop should be ticking but isn't. It ticks while the
+// round runs or is paused, not between rounds.
+func (c *Checker) stalled(s Status) bool {
+	if s.Tick.LastTick.IsZero() || (s.Round.Status != game.GameRunning && s.Round.Status != game.GamePaused) {
+		return false
+	}
+	return time.Since(s.Tick.LastTick) > stallTimeout
+}
+
+// healthz fails only if the game loop is stalled, restarting the server is the fix.
+func (c *Checker) healthz(w http.ResponseWriter, r *http.Request) {
+	s := c.Status()
+	code := http.StatusOK
+	if s.Status == StatusStalled {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, s)
+}
+
+// readyz fails while the server can't take players: starting, stalled or draining.
+func (c *Checker) readyz(w http.ResponseWriter, r *http.Request) {
+	s := c.Status()
+	code := http.StatusOK
+	if s.Status != StatusOK && s.Status != StatusDegraded {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, s)
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
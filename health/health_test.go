@@ -0,0 +1,73 @@
+package health
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/game"
+)
+
+// testGame is a game whose loop last ticked at lastTick, the zero time if it never did.
+type testGame struct {
+	status   game.GameStatus
+	lastTick time.Time
+}
+
+func (g testGame) GetGameID() uint { return 1 }
+
+func (g testGame) Snapshot() *game.Snapshot {
+	return &game.Snapshot{Status: g.status, Remaining: time.Minute}
+}
+
+func (g testGame) TickStats() game.TickStats {
+	return game.TickStats{Rate: 30, LastTick: g.lastTick}
+}
+
+func TestStatus(t *testing.T) {
+	stalled := time.Now().Add(-2 * stallTimeout)
+	tests := []struct {
+		name     string
+		game     testGame
+		draining bool
+		want     string
+		healthy  bool
+		ready    bool
+	}{
+		{"running", testGame{game.GameRunning, time.Now()}, false, StatusOK, true, true},
+		{"starting", testGame{game.GameRunning, time.Time{}}, false, StatusStarting, true, false},
+		{"starting while draining", testGame{game.GameRunning, time.Time{}}, true, StatusStarting, true, false},
+		{"stalled running", testGame{game.GameRunning, stalled}, false, StatusStalled, false, false},
+		{"stalled paused", testGame{game.GamePaused, stalled}, false, StatusStalled, false, false},
+		{"stalled while draining", testGame{game.GameRunning, stalled}, true, StatusStalled, false, false},
+		// the loop doesn't tick between rounds
+		{"between rounds", testGame{game.GameStopped, stalled}, false, StatusOK, true, true},
+		{"draining", testGame{game.GameRunning, time.Now()}, true, StatusDraining, true, false},
+	}
+	for _, tt := range tests {
+		d := db.NewMemoryClient()
+		c := &Checker{db: d, writer: db.NewWriter(d, db.WriterConfig{}), game: tt.game}
+		if tt.draining {
+			c.Drain()
+		}
+		s := c.Status()
+		if s.Status != tt.want {
+			t.Errorf("%s: status %q, want %q", tt.name, s.Status, tt.want)
+		}
+		if s.Round.GameID != 1 || s.Round.Remaining != 60 {
+			t.Errorf("%s: round %+v", tt.name, s.Round)
+		}
+
+		mux := http.NewServeMux()
+		c.Routes(mux)
+		for path, ok := range map[string]bool{"/healthz": tt.healthy, "/readyz": tt.ready} {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+			if got := w.Code == http.StatusOK; got != ok {
+				t.Errorf("%s: %s answered %d", tt.name, path, w.Code)
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+const (
+	TypeIRC   = "irc"
+	TypeFile  = "file"
+	TypeStdin = "stdin"
+)
+
+// Message is a chat line received from an external chat source.
+type Message struct {
+	Source   string
+	Username string
+	Text     string
+}
+
+// Source is an external chat, such as a live-stream chat, whose messages are
+// fed into the chat and vote pipeline.
+type Source interface {
+	// Name identifies the source, it namespaces the usernames it emits.
+	Name() string
+	// Run delivers messages to out until ctx is done or the source is exhausted.
+	Run(ctx context.Context, out chan<- Message) error
+}
+
+type Config struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+
+	// irc
+	Addr     string `json:"addr"`
+	TLS      bool   `json:"tls"`
+	Nick     string `json:"nick"`
+	Password string `json:"password"`
+	Channel  string `json:"channel"`
+
+	// file
+	Path string `json:"path"`
+}
+
+func New(cfg Config) (Source, error) {
+	name := cfg.Name
+	if name == "" {
+		name = cfg.Type
+	}
+	switch cfg.Type {
+	case TypeIRC:
+		if cfg.Addr == "" || cfg.Nick == "" || cfg.Channel == "" {
+			return nil, fmt.Errorf("ingest %s: irc source requires addr, nick and channel", name)
+		}
+		return NewIRC(name, cfg.Addr, cfg.TLS, cfg.Nick, cfg.Password, cfg.Channel), nil
+	case TypeFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("ingest %s: file source requires path", name)
+		}
+		return NewFile(name, cfg.Path), nil
+	case TypeStdin:
+		return NewStdin(name), nil
+	}
+	return nil, fmt.Errorf("ingest %s: unknown source type %q", name, cfg.Type)
+}
+
+// PlayerID maps an external username to a stable player id. The id is kept
+// within the int64 range so it fits the players table.
+func PlayerID(source, username string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(source))
+	h.Write([]byte{0})
+	h.Write([]byte(username))
+	return h.Sum64() & math.MaxInt64
+}
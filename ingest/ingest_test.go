@@ -0,0 +1,127 @@
+package ingest
+
+import (
+	"context"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseIRCLine(t *testing.T) {
+	tests := []struct {
+		line string
+		want ircLine
+	}{
+		{
+			"@badge-info=;color=#FF0000;display-name=Alice :alice!alice@alice.tmi.twitch.tv PRIVMSG #zecrey :go btc",
+			ircLine{nick: "alice", command: "PRIVMSG", params: []string{"#zecrey"}, trailing: "go btc"},
+		},
+		{
+			":bob!bob@host PRIVMSG #zecrey :eth: to the moon",
+			ircLine{nick: "bob", command: "PRIVMSG", params: []string{"#zecrey"}, trailing: "eth: to the moon"},
+		},
+		{"PING :tmi.twitch.tv", ircLine{command: "PING", params: []string{}, trailing: "tmi.twitch.tv"}},
+		{"PING tmi.twitch.tv", ircLine{command: "PING", params: []string{"tmi.twitch.tv"}}},
+		{":irc.example.com 001 bot :Welcome", ircLine{nick: "irc.example.com", command: "001", params: []string{"bot"}, trailing: "Welcome"}},
+		{"privmsg #zecrey :lower case command", ircLine{command: "PRIVMSG", params: []string{"#zecrey"}, trailing: "lower case command"}},
+		{"", ircLine{}},
+		{"@tags-only", ircLine{}},
+		{":prefix-only", ircLine{nick: "prefix-only"}},
+		{":nick!user@host", ircLine{nick: "nick"}},
+		{"   ", ircLine{}},
+	}
+	for _, tt := range tests {
+		got := parseIRCLine(tt.line)
+		if got.nick != tt.want.nick || got.command != tt.want.command || got.trailing != tt.want.trailing ||
+			len(got.params) != len(tt.want.params) || (len(got.params) > 0 && !reflect.DeepEqual(got.params, tt.want.params)) {
+			t.Errorf("parseIRCLine(%q) = %+v, want %+v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestReader(t *testing.T) {
+	input := "alice: go btc\n" +
+		"no separator\n" +
+		": no username\n" +
+		"bob:\n" +
+		"  carol  :  eth: to the moon  \n" +
+		"\n"
+	r := &Reader{name: "replay", open: func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader(input)), nil }}
+
+	out := make(chan Message, 10)
+	if err := r.Run(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	close(out)
+	var got []Message
+	for m := range out {
+		got = append(got, m)
+	}
+	want := []Message{
+		{Source: "replay", Username: "alice", Text: "go btc"},
+		{Source: "replay", Username: "carol", Text: "eth: to the moon"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestReaderFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "chat.log")
+	if err := os.WriteFile(path, []byte("alice: hi\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	out := make(chan Message, 1)
+	if err := NewFile("file", path).Run(context.Background(), out); err != nil {
+		t.Fatal(err)
+	}
+	if m := <-out; m != (Message{Source: "file", Username: "alice", Text: "hi"}) {
+		t.Errorf("got %+v", m)
+	}
+
+	if err := NewFile("file", filepath.Join(t.TempDir(), "missing")).Run(context.Background(), out); err == nil {
+		t.Error("a missing file didn't fail")
+	}
+}
+
+func TestReaderCancel(t *testing.T) {
+	r := &Reader{name: "replay", open: func() (io.ReadCloser, error) { return io.NopCloser(strings.NewReader("alice: hi\n")), nil }}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// nobody reads out, the reader must give up on ctx
+	if err := r.Run(ctx, make(chan Message)); err != context.Canceled {
+		t.Errorf("got %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestPlayerID(t *testing.T) {
+	tests := []struct {
+		source, username string
+	}{
+		{"twitch", "alice"},
+		{"twitch", "bob"},
+		{"youtube", "alice"},
+		// the separator keeps "ab"+"c" and "a"+"bc" apart
+		{"ab", "c"},
+		{"a", "bc"},
+		{"", ""},
+	}
+	seen := map[uint64]string{}
+	for _, tt := range tests {
+		id := PlayerID(tt.source, tt.username)
+		if id != PlayerID(tt.source, tt.username) {
+			t.Errorf("PlayerID(%q, %q) is not stable", tt.source, tt.username)
+		}
+		if id > math.MaxInt64 {
+			t.Errorf("PlayerID(%q, %q) = %d overflows int64", tt.source, tt.username, id)
+		}
+		if prev, ok := seen[id]; ok {
+			t.Errorf("PlayerID(%q, %q) collides with %s", tt.source, tt.username, prev)
+		}
+		seen[id] = tt.source + "/" + tt.username
+	}
+}
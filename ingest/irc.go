@@ -0,0 +1,150 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	ircDialTimeout    = 10 * time.Second
+	ircReadTimeout    = 5 * time.Minute
+	ircMaxReconnect   = time.Minute
+	ircStartReconnect = time.Second
+)
+
+// IRC is a reference Source speaking the IRC client protocol. It joins a single
+// channel and emits its PRIVMSG lines; most live-stream chats expose such a gateway.
+type IRC struct {
+	name     string
+	addr     string
+	tls      bool
+	nick     string
+	password string
+	channel  string
+}
+
+func NewIRC(name, addr string, useTLS bool, nick, password, channel string) *IRC {
+	if !strings.HasPrefix(channel, "#") {
+		channel = "#" + channel
+	}
+	return &IRC{name: name, addr: addr, tls: useTLS, nick: nick, password: password, channel: channel}
+}
+
+func (c *IRC) Name() string {
+	return c.name
+}
+
+// Run keeps the connection alive, reconnecting with backoff, until ctx is done.
+func (c *IRC) Run(ctx context.Context, out chan<- Message) error {
+	backoff := ircStartReconnect
+	for {
+		start := time.Now()
+		err := c.session(ctx, out)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if time.Since(start) > ircMaxReconnect {
+			backoff = ircStartReconnect
+		}
+		zap.L().Warn("irc connection lost, reconnecting", zap.String("source", c.name), zap.Duration("backoff", backoff), zap.Error(err))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > ircMaxReconnect {
+			backoff = ircMaxReconnect
+		}
+	}
+}
+
+func (c *IRC) session(ctx context.Context, out chan<- Message) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	// closing conn unblocks the read when ctx is done, the goroutine ends with the session
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		conn.Close()
+	}()
+
+	if c.password != "" {
+		fmt.Fprintf(conn, "PASS %s\r\n", c.password)
+	}
+	fmt.Fprintf(conn, "NICK %s\r\n", c.nick)
+	fmt.Fprintf(conn, "USER %s 0 * :%s\r\n", c.nick, c.nick)
+	fmt.Fprintf(conn, "JOIN %s\r\n", c.channel)
+
+	reader := bufio.NewReader(conn)
+	for {
+		conn.SetReadDeadline(time.Now().Add(ircReadTimeout))
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return err
+		}
+		l := parseIRCLine(strings.TrimRight(line, "\r\n"))
+		switch l.command {
+		case "PING":
+			if l.trailing == "" && len(l.params) > 0 {
+				l.trailing = l.params[0]
+			}
+			fmt.Fprintf(conn, "PONG :%s\r\n", l.trailing)
+		case "PRIVMSG":
+			if len(l.params) == 0 || !strings.EqualFold(l.params[0], c.channel) || l.nick == "" {
+				continue
+			}
+			select {
+			case out <- Message{Source: c.name, Username: l.nick, Text: l.trailing}:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+	}
+}
+
+func (c *IRC) dial(ctx context.Context) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: ircDialTimeout}
+	if c.tls {
+		host, _, _ := net.SplitHostPort(c.addr)
+		return (&tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}).DialContext(ctx, "tcp", c.addr)
+	}
+	return dialer.DialContext(ctx, "tcp", c.addr)
+}
+
+type ircLine struct {
+	nick     string
+	command  string
+	params   []string
+	trailing string
+}
+
+// parseIRCLine parses "[@tags] [:prefix] COMMAND [params] [:trailing]".
+func parseIRCLine(line string) ircLine {
+	var l ircLine
+	if strings.HasPrefix(line, "@") {
+		_, line, _ = strings.Cut(line, " ")
+	}
+	if strings.HasPrefix(line, ":") {
+		var prefix string
+		prefix, line, _ = strings.Cut(line[1:], " ")
+		l.nick, _, _ = strings.Cut(prefix, "!")
+	}
+	line, l.trailing, _ = strings.Cut(line, " :")
+	fields := strings.Fields(line)
+	if len(fields) > 0 {
+		l.command = strings.ToUpper(fields[0])
+		l.params = fields[1:]
+	}
+	return l
+}
@@ -0,0 +1,51 @@
+package ingest
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"os"
+	"strings"
+)
+
+// Reader reads "username: message" lines from a file or stdin. It is meant
+// for testing the pipeline and replaying recorded chats.
+type Reader struct {
+	name string
+	open func() (io.ReadCloser, error)
+}
+
+func NewFile(name, path string) *Reader {
+	return &Reader{name: name, open: func() (io.ReadCloser, error) { return os.Open(path) }}
+}
+
+func NewStdin(name string) *Reader {
+	return &Reader{name: name, open: func() (io.ReadCloser, error) { return io.NopCloser(os.Stdin), nil }}
+}
+
+func (r *Reader) Name() string {
+	return r.name
+}
+
+func (r *Reader) Run(ctx context.Context, out chan<- Message) error {
+	rc, err := r.open()
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	scanner := bufio.NewScanner(rc)
+	for scanner.Scan() {
+		username, text, ok := strings.Cut(scanner.Text(), ":")
+		username, text = strings.TrimSpace(username), strings.TrimSpace(text)
+		if !ok || username == "" || text == "" {
+			continue
+		}
+		select {
+		case out <- Message{Source: r.name, Username: username, Text: text}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	cfg "github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/loadtest"
+)
+
+const defaultLoadTestDuration = time.Minute
+
+func loadTest(conf *cfg.Config, args []string) {
+	lc, err := loadTestConfig(conf, args)
+	if err == flag.ErrHelp {
+		os.Exit(0)
+	} else if err != nil {
+		// the flag set printed it
+		os.Exit(2)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	report, err := loadtest.Run(ctx, lc)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	if err := report.WriteJSON(os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+// loadTestConfig parses the loadtest flags, the frame rate defaults to the server's.
+func loadTestConfig(conf *cfg.Config, args []string) (loadtest.Config, error) {
+	fs := flag.NewFlagSet("loadtest", flag.ContinueOnError)
+	addr := fs.String("addr", "localhost:3250", "WebSocket address of the server")
+	bots := fs.Int("bots", 1000, "number of simulated clients")
+	ramp := fs.Duration("ramp", 0, "time over which the bots connect (default all at once)")
+	duration := fs.Duration("duration", defaultLoadTestDuration, "how long each bot stays connected")
+	messageRate := fs.Float64("message-rate", 0.2, "chat messages per bot per second")
+	voteRate := fs.Float64("vote-rate", 0.05, "votes per bot per second")
+	fps := fs.Int("fps", conf.FPS, "frame rate the server should deliver")
+	idBase := fs.Uint64("id-base", 1<<40, "player id of the first bot")
+	if err := fs.Parse(args); err != nil {
+		return loadtest.Config{}, err
+	}
+	return loadtest.Config{
+		Addr:        *addr,
+		Bots:        *bots,
+		Ramp:        *ramp,
+		Duration:    *duration,
+		MessageRate: *messageRate,
+		VoteRate:    *voteRate,
+		FPS:         *fps,
+		IDBase:      *idBase,
+	}, nil
+}
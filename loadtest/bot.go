@@ -0,0 +1,139 @@
+package loadtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/game"
+	"github.com/COAOX/zecrey_warrior/model"
+	"github.com/topfreegames/pitaya/v2/conn/message"
+)
+
+var chatter = []string{"gg", "let's go", "nice", "wow", "so close", "haha"}
+
+type request struct {
+	route  string
+	sentAt time.Time
+	done   chan struct{} // closed on response
+}
+
+type bot struct {
+	id    uint64
+	cfg   Config
+	stats *stats
+	conn  *conn
+
+	mu      sync.Mutex // held while sending, so that a response is never handled before its request is recorded
+	pending map[uint]request
+	frames  int64
+}
+
+func (b *bot) run(ctx context.Context) {
+	c, err := dial(b.cfg.Addr)
+	if err != nil {
+		b.stats.connectFailed(err)
+		return
+	}
+	b.conn = c
+	b.stats.connected()
+	defer c.close()
+	go b.receive()
+
+	// join like the web client does, one room after the other, before chatting
+	player := model.Player{PlayerID: b.id, Name: fmt.Sprintf("bot-%d", b.id)}
+	for _, join := range []struct {
+		route string
+		v     interface{}
+	}{{routeChatJoin, player}, {routeGameJoin, []byte("{}")}} {
+		select {
+		case <-b.request(join.route, join.v):
+		case <-c.closed:
+			b.stats.dropped(0, 0)
+			return
+		case <-ctx.Done():
+			return
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, b.cfg.Duration)
+	defer cancel()
+	messages, votes := ticker(b.cfg.MessageRate), ticker(b.cfg.VoteRate)
+	defer messages.Stop()
+	defer votes.Stop()
+
+	start := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			b.stats.finished(atomic.LoadInt64(&b.frames), time.Since(start))
+			return
+		case <-c.closed:
+			b.stats.dropped(atomic.LoadInt64(&b.frames), time.Since(start))
+			return
+		case <-messages.C:
+			b.request(routeChatMessage, model.Message{PlayerID: b.id, Message: chatter[rand.Intn(len(chatter))]})
+		case <-votes.C:
+			camp := game.CampTagMap[game.Camp(1+rand.Intn(len(game.CampTagMap)-1))]
+			b.request(routeChatMessage, model.Message{PlayerID: b.id, Message: "go " + camp})
+		}
+	}
+}
+
+// request sends v, JSON encoded unless it is raw bytes, and returns a channel closed on response.
+func (b *bot) request(route string, v interface{}) <-chan struct{} {
+	data, ok := v.([]byte)
+	if !ok {
+		data, _ = json.Marshal(v)
+	}
+	req := request{route: route, sentAt: time.Now(), done: make(chan struct{})}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	id, err := b.conn.request(route, data)
+	if err != nil {
+		b.stats.failed(route)
+		close(req.done)
+		return req.done
+	}
+	b.pending[id] = req
+	return req.done
+}
+
+func (b *bot) receive() {
+	for {
+		select {
+		case <-b.conn.closed:
+			return
+		case msg := <-b.conn.in:
+			switch msg.Type {
+			case message.Push:
+				if msg.Route == routeGameUpdate {
+					atomic.AddInt64(&b.frames, 1)
+				}
+			case message.Response:
+				b.mu.Lock()
+				req, ok := b.pending[msg.ID]
+				delete(b.pending, msg.ID)
+				b.mu.Unlock()
+				if ok {
+					b.stats.responded(req.route, time.Since(req.sentAt), msg)
+					close(req.done)
+				}
+			}
+		}
+	}
+}
+
+// ticker ticks rate times per second, or never if rate is 0.
+func ticker(rate float64) *time.Ticker {
+	if rate <= 0 {
+		t := time.NewTicker(time.Hour)
+		t.Stop()
+		return t
+	}
+	return time.NewTicker(time.Duration(float64(time.Second) / rate))
+}
@@ -0,0 +1,177 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/topfreegames/pitaya/v2/conn/codec"
+	"github.com/topfreegames/pitaya/v2/conn/message"
+	"github.com/topfreegames/pitaya/v2/conn/packet"
+	"github.com/topfreegames/pitaya/v2/session"
+	"github.com/topfreegames/pitaya/v2/util/compression"
+)
+
+const (
+	dialTimeout     = 10 * time.Second
+	incomingBacklog = 64
+)
+
+var (
+	packetEncoder  = codec.NewPomeloPacketEncoder()
+	packetDecoder  = codec.NewPomeloPacketDecoder()
+	messageEncoder = message.NewMessagesEncoder(false)
+
+	handshake = session.HandshakeData{Sys: session.HandshakeClientData{Platform: "loadtest", LibVersion: "0.1", BuildNumber: "1", Version: "1"}}
+)
+
+type handshakeResponse struct {
+	Code int `json:"code"`
+	Sys  struct {
+		Heartbeat int `json:"heartbeat"` // seconds
+	} `json:"sys"`
+}
+
+// conn is a minimal pitaya client over WebSocket. Unlike pitaya's own client it
+// serializes its writes, which its heartbeats otherwise race with.
+type conn struct {
+	ws     *websocket.Conn
+	mu     sync.Mutex // guards writes to ws
+	nextID uint32
+
+	in        chan *message.Message
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func dial(addr string) (*conn, error) {
+	u := url.URL{Scheme: "ws", Host: addr, Path: "/"}
+	dialer := websocket.Dialer{HandshakeTimeout: dialTimeout}
+	ws, _, err := dialer.Dial(u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &conn{ws: ws, in: make(chan *message.Message, incomingBacklog), closed: make(chan struct{})}
+	heartbeat, err := c.handshake()
+	if err != nil {
+		ws.Close()
+		return nil, err
+	}
+	go c.read()
+	go c.heartbeat(heartbeat)
+	return c, nil
+}
+
+func (c *conn) handshake() (time.Duration, error) {
+	data, _ := json.Marshal(handshake)
+	if err := c.write(packet.Handshake, data); err != nil {
+		return 0, err
+	}
+	c.ws.SetReadDeadline(time.Now().Add(dialTimeout))
+	defer c.ws.SetReadDeadline(time.Time{})
+	_, b, err := c.ws.ReadMessage()
+	if err != nil {
+		return 0, err
+	}
+	packets, err := packetDecoder.Decode(b)
+	if err != nil {
+		return 0, err
+	}
+	if len(packets) == 0 || packets[0].Type != packet.Handshake {
+		return 0, fmt.Errorf("expected a handshake from the server")
+	}
+	data = packets[0].Data
+	if compression.IsCompressed(data) {
+		if data, err = compression.InflateData(data); err != nil {
+			return 0, err
+		}
+	}
+	var resp handshakeResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return 0, err
+	}
+	if err := c.write(packet.HandshakeAck, nil); err != nil {
+		return 0, err
+	}
+	return time.Duration(resp.Sys.Heartbeat) * time.Second, nil
+}
+
+// request sends a request and returns its id, the response comes through c.in.
+func (c *conn) request(route string, data []byte) (uint, error) {
+	m := &message.Message{Type: message.Request, ID: uint(atomic.AddUint32(&c.nextID, 1)), Route: route, Data: data}
+	encoded, err := messageEncoder.Encode(m)
+	if err != nil {
+		return 0, err
+	}
+	return m.ID, c.write(packet.Data, encoded)
+}
+
+func (c *conn) write(typ packet.Type, data []byte) error {
+	p, err := packetEncoder.Encode(typ, data)
+	if err != nil {
+		return err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.ws.WriteMessage(websocket.BinaryMessage, p)
+}
+
+func (c *conn) read() {
+	defer c.close()
+	for {
+		_, b, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		packets, err := packetDecoder.Decode(b)
+		if err != nil {
+			return
+		}
+		for _, p := range packets {
+			switch p.Type {
+			case packet.Data:
+				m, err := message.Decode(p.Data)
+				if err != nil {
+					return
+				}
+				select {
+				case c.in <- m:
+				case <-c.closed:
+					return
+				}
+			case packet.Kick:
+				return
+			}
+		}
+	}
+}
+
+func (c *conn) heartbeat(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			if err := c.write(packet.Heartbeat, nil); err != nil {
+				c.close()
+				return
+			}
+		case <-c.closed:
+			return
+		}
+	}
+}
+
+func (c *conn) close() {
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		c.ws.Close()
+	})
+}
@@ -0,0 +1,64 @@
+// Package loadtest runs a fleet of bots speaking the pitaya WebSocket protocol
+// against a local server and measures how it copes.
+package loadtest
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	routeChatJoin    = "chat.join"
+	routeChatMessage = "chat.message"
+	routeGameJoin    = "game.join"
+	routeGameUpdate  = "onUpdate"
+)
+
+type Config struct {
+	Addr     string // host:port of the WebSocket acceptor
+	Bots     int
+	Ramp     time.Duration // bots connect evenly over this period
+	Duration time.Duration // how long every bot stays once connected
+	// MessageRate and VoteRate are per bot, per second. A vote is a chat message naming a camp.
+	MessageRate float64
+	VoteRate    float64
+	// FPS is the frame rate the server is expected to deliver.
+	FPS    int
+	IDBase uint64 // player id of the first bot, the others follow
+}
+
+// Run starts cfg.Bots bots and waits for them to finish, or for ctx to be done.
+func Run(ctx context.Context, cfg Config) (Report, error) {
+	if cfg.Bots <= 0 {
+		return Report{}, fmt.Errorf("bots must be positive, got %d", cfg.Bots)
+	}
+	if cfg.Duration <= 0 {
+		return Report{}, fmt.Errorf("duration must be positive, got %v", cfg.Duration)
+	}
+
+	s := newStats()
+	start := time.Now()
+	var wg sync.WaitGroup
+	interval := cfg.Ramp / time.Duration(cfg.Bots)
+	for i := 0; i < cfg.Bots; i++ {
+		if i > 0 && interval > 0 {
+			select {
+			case <-time.After(interval):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		b := &bot{id: cfg.IDBase + uint64(i), cfg: cfg, stats: s, pending: map[uint]request{}}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.run(ctx)
+		}()
+	}
+	wg.Wait()
+	return s.report(cfg, time.Since(start)), nil
+}
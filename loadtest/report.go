@@ -0,0 +1,173 @@
+package loadtest
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/topfreegames/pitaya/v2/conn/message"
+)
+
+// maxConnectErrors bounds the distinct connection errors kept for the report.
+const maxConnectErrors = 10
+
+type stats struct {
+	mu sync.Mutex
+
+	connections   int
+	connectFails  int
+	connectErrors map[string]int
+	drops         int
+	frameRates    []float64 // frames per second received by each bot that stayed or dropped
+	latencies     map[string][]time.Duration
+	errors        map[string]int
+	errorSamples  map[string]string // first error response of each route
+	sendFails     map[string]int
+}
+
+func newStats() *stats {
+	return &stats{
+		connectErrors: map[string]int{},
+		latencies:     map[string][]time.Duration{},
+		errors:        map[string]int{},
+		errorSamples:  map[string]string{},
+		sendFails:     map[string]int{},
+	}
+}
+
+func (s *stats) connected() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connections++
+}
+
+func (s *stats) connectFailed(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.connectFails++
+	if _, ok := s.connectErrors[err.Error()]; ok || len(s.connectErrors) < maxConnectErrors {
+		s.connectErrors[err.Error()]++
+	}
+}
+
+func (s *stats) finished(frames int64, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.frameRates = append(s.frameRates, float64(frames)/d.Seconds())
+}
+
+func (s *stats) dropped(frames int64, d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drops++
+	if d > 0 {
+		s.frameRates = append(s.frameRates, float64(frames)/d.Seconds())
+	}
+}
+
+func (s *stats) responded(route string, latency time.Duration, msg *message.Message) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.latencies[route] = append(s.latencies[route], latency)
+	if msg.Err {
+		s.errors[route]++
+		if _, ok := s.errorSamples[route]; !ok {
+			s.errorSamples[route] = string(msg.Data)
+		}
+	}
+}
+
+func (s *stats) failed(route string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sendFails[route]++
+}
+
+// Latency is in milliseconds.
+type Latency struct {
+	Requests    int     `json:"requests"`
+	Errors      int     `json:"errors"`
+	ErrorSample string  `json:"error_sample,omitempty"`
+	SendFailed  int     `json:"send_failed"`
+	P50         float64 `json:"p50_ms"`
+	P95         float64 `json:"p95_ms"`
+	P99         float64 `json:"p99_ms"`
+	Max         float64 `json:"max_ms"`
+}
+
+type Report struct {
+	Bots          int                `json:"bots"`
+	Connected     int                `json:"connected"`
+	ConnectFailed int                `json:"connect_failed"`
+	ConnectErrors map[string]int     `json:"connect_errors,omitempty"`
+	Dropped       int                `json:"dropped"`
+	Elapsed       float64            `json:"elapsed_seconds"`
+	ExpectedFPS   int                `json:"expected_fps"`
+	AvgFPS        float64            `json:"avg_fps"`
+	MinFPS        float64            `json:"min_fps"`
+	DeliveryRate  float64            `json:"delivery_rate"` // AvgFPS / ExpectedFPS
+	Routes        map[string]Latency `json:"routes"`
+}
+
+func (s *stats) report(cfg Config, elapsed time.Duration) Report {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r := Report{
+		Bots:          cfg.Bots,
+		Connected:     s.connections,
+		ConnectFailed: s.connectFails,
+		ConnectErrors: s.connectErrors,
+		Dropped:       s.drops,
+		Elapsed:       elapsed.Seconds(),
+		ExpectedFPS:   cfg.FPS,
+		Routes:        map[string]Latency{},
+	}
+	if len(s.frameRates) > 0 {
+		r.MinFPS = s.frameRates[0]
+		for _, fps := range s.frameRates {
+			r.AvgFPS += fps / float64(len(s.frameRates))
+			if fps < r.MinFPS {
+				r.MinFPS = fps
+			}
+		}
+	}
+	if cfg.FPS > 0 {
+		r.DeliveryRate = r.AvgFPS / float64(cfg.FPS)
+	}
+	for route, l := range s.latencies {
+		sort.Slice(l, func(i, j int) bool { return l[i] < l[j] })
+		r.Routes[route] = Latency{
+			Requests: len(l),
+			Errors:   s.errors[route],
+
+			ErrorSample: s.errorSamples[route],
+			P50:         percentile(l, 0.50),
+			P95:         percentile(l, 0.95),
+			P99:         percentile(l, 0.99),
+			Max:         percentile(l, 1),
+		}
+	}
+	for route, n := range s.sendFails {
+		l := r.Routes[route]
+		l.SendFailed = n
+		r.Routes[route] = l
+	}
+	return r
+}
+
+// percentile returns the p-th percentile of sorted, in milliseconds.
+func percentile(sorted []time.Duration, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p * float64(len(sorted)-1))
+	return float64(sorted[i]) / float64(time.Millisecond)
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,70 @@
+package loadtest
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/topfreegames/pitaya/v2/conn/message"
+)
+
+func TestReport(t *testing.T) {
+	s := newStats()
+	for i := 0; i < 3; i++ {
+		s.connected()
+	}
+	s.connectFailed(errors.New("connection refused"))
+	s.connectFailed(errors.New("connection refused"))
+	s.finished(600, 20*time.Second) // 30 fps
+	s.finished(400, 20*time.Second) // 20 fps
+	s.dropped(100, 10*time.Second)  // 10 fps
+	s.dropped(0, 0)                 // before any frame, no rate
+	// 1 to 100 ms
+	for i := 100; i >= 1; i-- {
+		msg := &message.Message{}
+		if i%25 == 0 {
+			msg = &message.Message{Err: true, Data: []byte(fmt.Sprintf(`{"code":"RH-%d"}`, i))}
+		}
+		s.responded(routeChatMessage, time.Duration(i)*time.Millisecond, msg)
+	}
+	s.failed(routeChatMessage)
+	s.failed(routeGameJoin)
+
+	r := s.report(Config{Bots: 5, FPS: 30}, 90*time.Second)
+	if r.Bots != 5 || r.Connected != 3 || r.ConnectFailed != 2 || r.ConnectErrors["connection refused"] != 2 || r.Dropped != 2 || r.Elapsed != 90 {
+		t.Errorf("report %+v", r)
+	}
+	if r.ExpectedFPS != 30 || math.Abs(r.AvgFPS-20) > 1e-9 || r.MinFPS != 10 || math.Abs(r.DeliveryRate-2.0/3) > 1e-9 {
+		t.Errorf("expected %d fps, avg %v, min %v, delivery %v; want 30, 20, 10, 0.667", r.ExpectedFPS, r.AvgFPS, r.MinFPS, r.DeliveryRate)
+	}
+
+	want := Latency{Requests: 100, Errors: 4, ErrorSample: `{"code":"RH-100"}`, SendFailed: 1, P50: 50, P95: 95, P99: 99, Max: 100}
+	if got := r.Routes[routeChatMessage]; got != want {
+		t.Errorf("%s latency %+v, want %+v", routeChatMessage, got, want)
+	}
+	// a route whose requests all failed to send is still reported
+	if got := r.Routes[routeGameJoin]; got != (Latency{SendFailed: 1}) {
+		t.Errorf("%s latency %+v, want only the send failure", routeGameJoin, got)
+	}
+}
+
+func TestReportEmpty(t *testing.T) {
+	r := newStats().report(Config{Bots: 1}, time.Second)
+	if r.AvgFPS != 0 || r.MinFPS != 0 || r.DeliveryRate != 0 || len(r.Routes) != 0 {
+		t.Errorf("report %+v of no bot, want zeros", r)
+	}
+}
+
+func TestConnectErrorsBounded(t *testing.T) {
+	s := newStats()
+	for i := 0; i < maxConnectErrors+5; i++ {
+		s.connectFailed(fmt.Errorf("error %d", i))
+	}
+	s.connectFailed(errors.New("error 0"))
+	r := s.report(Config{}, time.Second)
+	if r.ConnectFailed != maxConnectErrors+6 || len(r.ConnectErrors) != maxConnectErrors || r.ConnectErrors["error 0"] != 2 {
+		t.Errorf("%d failures, errors %v; want %d failures, %d distinct errors counted", r.ConnectFailed, r.ConnectErrors, maxConnectErrors+6, maxConnectErrors)
+	}
+}
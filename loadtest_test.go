@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	cfg "github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/loadtest"
+)
+
+func TestLoadTestConfig(t *testing.T) {
+	conf := &cfg.Config{FPS: 20}
+	c, err := loadTestConfig(conf, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := loadtest.Config{Addr: "localhost:3250", Bots: 1000, Duration: defaultLoadTestDuration, MessageRate: 0.2, VoteRate: 0.05, FPS: 20, IDBase: 1 << 40}
+	if c != want {
+		t.Errorf("defaults %+v, want %+v", c, want)
+	}
+
+	c, err = loadTestConfig(conf, []string{
+		"-addr", "example.com:9000", "-bots", "50", "-ramp", "10s", "-duration", "2m",
+		"-message-rate", "1.5", "-vote-rate", "0", "-fps", "60", "-id-base", "7",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want = loadtest.Config{Addr: "example.com:9000", Bots: 50, Ramp: 10 * time.Second, Duration: 2 * time.Minute, MessageRate: 1.5, FPS: 60, IDBase: 7}
+	if c != want {
+		t.Errorf("flags %+v, want %+v", c, want)
+	}
+
+	for _, args := range [][]string{{"-bots", "many"}, {"-ramp", "10"}, {"-unknown"}} {
+		if _, err := loadTestConfig(conf, args); err == nil {
+			t.Errorf("%v: no error", args)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+// Package logging sets up the one logger of the server. Code logs through zap.L(),
+// pitaya through an adapter of the same logger.
+package logging
+
+import (
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/topfreegames/pitaya/v2/logger"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// New builds the logger described by cfg.
+func New(cfg config.LogConfig) (*zap.Logger, error) {
+	zc := zap.NewProductionConfig()
+	if cfg.Format == config.LogFormatConsole {
+		zc = zap.NewDevelopmentConfig()
+		zc.Development = false
+	}
+	level := zapcore.InfoLevel
+	if cfg.Level != "" {
+		if err := level.Set(cfg.Level); err != nil {
+			return nil, err
+		}
+	}
+	zc.Level = zap.NewAtomicLevelAt(level)
+	zc.Sampling = nil // errors must not be sampled away
+	return zc.Build()
+}
+
+// Init makes the logger of cfg the global zap logger and pitaya's. The returned
+// function flushes it, before the process exits.
+func Init(cfg config.LogConfig) (func(), error) {
+	l, err := New(cfg)
+	if err != nil {
+		return nil, err
+	}
+	zap.ReplaceGlobals(l)
+	logger.SetLogger(Pitaya(l.With(zap.String("source", "pitaya"))))
+	return func() { l.Sync() }, nil
+}
+
+// The fields every log line about a round, a room or a player carries.
+
+func GameID(id uint) zap.Field {
+	return zap.Uint("game_id", id)
+}
+
+func Room(name string) zap.Field {
+	return zap.String("room", name)
+}
+
+func PlayerID(id uint64) zap.Field {
+	return zap.Uint64("player_id", id)
+}
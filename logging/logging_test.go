@@ -0,0 +1,61 @@
+package logging
+
+import (
+	"testing"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestFields(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+	zap.New(core).With(Room("game"), GameID(42)).Info("round started", PlayerID(7))
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("%d entries, want 1", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if fields["room"] != "game" || fields["game_id"] != uint64(42) || fields["player_id"] != uint64(7) {
+		t.Errorf("fields %v, want room game, game_id 42 and player_id 7", fields)
+	}
+}
+
+func TestPitaya(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	l := Pitaya(zap.New(core).With(zap.String("source", "pitaya")))
+	l.WithFields(map[string]interface{}{"route": "game.join"}).WithField("uid", "1").Infof("joined %d", 1)
+	l.Debug("not logged")
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("%d entries, want the info one", len(entries))
+	}
+	e := entries[0]
+	fields := e.ContextMap()
+	if e.Message != "joined 1" || fields["source"] != "pitaya" || fields["route"] != "game.join" || fields["uid"] != "1" {
+		t.Errorf("entry %q %v, want pitaya's fields", e.Message, fields)
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []config.LogConfig{{}, {Level: "debug", Format: config.LogFormatConsole}, {Level: "error", Format: config.LogFormatJSON}} {
+		l, err := New(cfg)
+		if err != nil {
+			t.Errorf("%+v: %v", cfg, err)
+			continue
+		}
+		want := zapcore.InfoLevel
+		if cfg.Level != "" {
+			want.Set(cfg.Level)
+		}
+		if !l.Core().Enabled(want) || (want > zapcore.DebugLevel && l.Core().Enabled(want-1)) {
+			t.Errorf("%+v: level isn't %v", cfg, want)
+		}
+	}
+	if _, err := New(config.LogConfig{Level: "verbose"}); err == nil {
+		t.Error("unknown level: no error")
+	}
+}
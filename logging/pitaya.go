@@ -0,0 +1,56 @@
+package logging
+
+import (
+	"github.com/topfreegames/pitaya/v2/logger/interfaces"
+	"go.uber.org/zap"
+)
+
+// pitayaLogger adapts a zap logger to the logger interface of pitaya.
+type pitayaLogger struct {
+	s *zap.SugaredLogger
+}
+
+// Pitaya returns l as a pitaya logger.
+func Pitaya(l *zap.Logger) interfaces.Logger {
+	return pitayaLogger{s: l.WithOptions(zap.AddCallerSkip(1)).Sugar()}
+}
+
+func (l pitayaLogger) Fatal(args ...interface{})                 { l.s.Fatal(args...) }
+func (l pitayaLogger) Fatalf(format string, args ...interface{}) { l.s.Fatalf(format, args...) }
+func (l pitayaLogger) Fatalln(args ...interface{})               { l.s.Fatal(args...) }
+
+func (l pitayaLogger) Debug(args ...interface{})                 { l.s.Debug(args...) }
+func (l pitayaLogger) Debugf(format string, args ...interface{}) { l.s.Debugf(format, args...) }
+func (l pitayaLogger) Debugln(args ...interface{})               { l.s.Debug(args...) }
+
+func (l pitayaLogger) Error(args ...interface{})                 { l.s.Error(args...) }
+func (l pitayaLogger) Errorf(format string, args ...interface{}) { l.s.Errorf(format, args...) }
+func (l pitayaLogger) Errorln(args ...interface{})               { l.s.Error(args...) }
+
+func (l pitayaLogger) Info(args ...interface{})                 { l.s.Info(args...) }
+func (l pitayaLogger) Infof(format string, args ...interface{}) { l.s.Infof(format, args...) }
+func (l pitayaLogger) Infoln(args ...interface{})               { l.s.Info(args...) }
+
+func (l pitayaLogger) Warn(args ...interface{})                 { l.s.Warn(args...) }
+func (l pitayaLogger) Warnf(format string, args ...interface{}) { l.s.Warnf(format, args...) }
+func (l pitayaLogger) Warnln(args ...interface{})               { l.s.Warn(args...) }
+
+func (l pitayaLogger) Panic(args ...interface{})                 { l.s.Panic(args...) }
+func (l pitayaLogger) Panicf(format string, args ...interface{}) { l.s.Panicf(format, args...) }
+func (l pitayaLogger) Panicln(args ...interface{})               { l.s.Panic(args...) }
+
+func (l pitayaLogger) WithFields(fields map[string]interface{}) interfaces.Logger {
+	kv := make([]interface{}, 0, 2*len(fields))
+	for k, v := range fields {
+		kv = append(kv, k, v)
+	}
+	return pitayaLogger{s: l.s.With(kv...)}
+}
+
+func (l pitayaLogger) WithField(key string, value interface{}) interfaces.Logger {
+	return pitayaLogger{s: l.s.With(key, value)}
+}
+
+func (l pitayaLogger) WithError(err error) interfaces.Logger {
+	return pitayaLogger{s: l.s.With(zap.Error(err))}
+}
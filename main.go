@@ -1,55 +1,154 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"flag"
-	"log"
+	"fmt"
 	"net/http"
+	"os"
 	"time"
 
+	"github.com/COAOX/zecrey_warrior/admin"
+	"github.com/COAOX/zecrey_warrior/api"
 	"github.com/COAOX/zecrey_warrior/chat"
 	cfg "github.com/COAOX/zecrey_warrior/config"
 	"github.com/COAOX/zecrey_warrior/db"
 	"github.com/COAOX/zecrey_warrior/game"
-	"github.com/sirupsen/logrus"
+	"github.com/COAOX/zecrey_warrior/health"
+	"github.com/COAOX/zecrey_warrior/logging"
+	"github.com/COAOX/zecrey_warrior/metrics"
+	"github.com/COAOX/zecrey_warrior/role"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/topfreegames/pitaya/v2"
 	"github.com/topfreegames/pitaya/v2/acceptor"
 	"github.com/topfreegames/pitaya/v2/config"
 	"github.com/topfreegames/pitaya/v2/groups"
-	"github.com/topfreegames/pitaya/v2/logger"
-	"github.com/topfreegames/pitaya/v2/logger/interfaces"
-	logruswrapper "github.com/topfreegames/pitaya/v2/logger/logrus"
+	"go.uber.org/zap"
 )
 
+const writerFlushTimeout = 10 * time.Second
+
 var (
 	configPath = flag.String("config", "./config/local.json", "Path to config file")
 )
 
 func main() {
+	flag.Usage = usage
 	flag.Parse()
-	cfg := cfg.Read(*configPath)
+	conf := cfg.Read(*configPath)
+	flush, err := logging.Init(conf.Log)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "init logger:", err)
+		os.Exit(2)
+	}
+	defer flush()
+
+	switch cmd := flag.Arg(0); cmd {
+	case "", "serve":
+		serve(conf)
+	case "migrate":
+		migrate(conf, flag.Args()[1:])
+	case "seed":
+		seed(conf)
+	case "simulate":
+		simulate(conf, flag.Args()[1:])
+	case "loadtest":
+		loadTest(conf, flag.Args()[1:])
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", cmd)
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprintf(flag.CommandLine.Output(), `Usage: %s [flags] [command]
 
-	builder := pitaya.NewDefaultBuilder(true, cfg.FrontendType, pitaya.Standalone, map[string]string{}, configApp())
+Commands:
+  serve                 run the game server (default)
+  migrate up            apply pending schema migrations and seed the missing camps,
+                        serve only checks that the schema is up to date
+  migrate down [steps]  revert the last applied migrations (default 1)
+  migrate status        list migrations and when they were applied
+  seed                  insert missing camps, existing rows are kept
+  simulate [flags]      run rounds headless and report camp win rates, see simulate -h
+  loadtest [flags]      connect a fleet of bots to a local server and report latencies, see loadtest -h
+
+Flags:
+`, os.Args[0])
+	flag.PrintDefaults()
+}
+
+func serve(conf *cfg.Config) {
+	builder := pitaya.NewDefaultBuilder(true, conf.FrontendType, pitaya.Standalone, map[string]string{}, configApp())
 	builder.AddAcceptor(acceptor.NewWSAcceptor(":3250"))
 	builder.Groups = groups.NewMemoryGroupService(*config.NewDefaultMemoryGroupConfig())
 	builder.Serializer = game.NewSerializer()
+	pool := &drainingPool{SessionPool: builder.SessionPool}
+	builder.SessionPool = pool
 	app := builder.Build()
 
 	defer app.Shutdown()
 
-	database := db.NewClient(cfg.Database)
+	database := db.NewClient(conf.Database)
+	database.Observe(metrics.ObserveDB)
+	if err := database.Prepare(); errors.Is(err, db.ErrSchemaOutdated) {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	} else if err != nil {
+		zap.L().Error("database unavailable, starting degraded", zap.Error(err))
+	}
+	writer := db.NewWriter(database, conf.Database.Writer)
+	prometheus.MustRegister(metrics.NewWriterCollector(writer))
 
 	// register game and chat
-	g := game.RegistRoom(app, database, cfg)
-	chat.RegistRoom(app, database, cfg, g)
-
-	log.SetFlags(log.LstdFlags | log.Llongfile)
+	g := game.RegistRoom(app, database, writer, conf)
+	chatRoom := chat.RegistRoom(app, database, writer, conf, g)
+	watcher := cfg.NewWatcher(*configPath, time.Duration(conf.ReloadInterval)*time.Second, g.Reload)
+	adminRoom := admin.RegistRoom(app, conf, g, chatRoom, watcher.Reload)
+	go watcher.Run(context.Background())
+	// once the rooms registered their degraded callbacks
+	go database.Monitor(context.Background())
 
-	http.Handle("/web/", http.StripPrefix("/web/", http.FileServer(http.Dir("web"))))
-	http.Handle("/demoweb/", http.StripPrefix("/demoweb/", http.FileServer(http.Dir("demoweb"))))
+	// not http.DefaultServeMux, pitaya serves it on the metrics port
+	mux := http.NewServeMux()
+	mux.Handle("/web/", http.StripPrefix("/web/", http.FileServer(http.Dir("web"))))
+	mux.Handle("/demoweb/", http.StripPrefix("/demoweb/", http.FileServer(http.Dir("demoweb"))))
+	mux.Handle("/metrics", promhttp.Handler())
+	adminRoom.Routes(mux)
+	checker := health.New(database, writer, g, func(token string) bool {
+		return role.Claim(conf, role.Moderator, token) == nil
+	})
+	checker.Routes(mux)
+	api.New(database, g).Routes(mux)
 
-	go http.ListenAndServe(":3251", nil)
+	srv := &http.Server{Addr: ":3251", Handler: mux}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			zap.L().Error("http server failed", zap.Error(err))
+		}
+	}()
 
+	stopCtx, stop := context.WithCancel(context.Background())
+	defer stop()
+	handleSignals(stop)
+	pool.shutdown = func() { shutdown(stopCtx, app, conf, g, checker) }
 	app.Start()
+
+	// flush the chat messages, votes and checkpoint still queued
+	ctx, cancel := context.WithTimeout(context.Background(), writerFlushTimeout)
+	defer cancel()
+	if err := writer.Close(ctx); err != nil {
+		zap.L().Error("flush pending writes failed", zap.Error(err), zap.Any("writer", writer.Stats()))
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), httpShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		zap.L().Error("http server shutdown failed", zap.Error(err))
+	}
 }
 
 func configApp() config.BuilderConfig {
@@ -58,18 +157,5 @@ func configApp() config.BuilderConfig {
 	conf.Pitaya.Buffer.Agent.Messages = 32
 	conf.Pitaya.Handler.Messages.Compression = false
 	conf.Metrics.Prometheus.Enabled = true
-	l := initLogger()
-	logger.SetLogger(l)
 	return *conf
 }
-
-func initLogger() interfaces.Logger {
-	plog := logrus.New()
-	plog.Formatter = new(logrus.TextFormatter)
-	plog.Level = logrus.ErrorLevel
-
-	log := plog.WithFields(logrus.Fields{
-		"source": "pitaya",
-	})
-	return logruswrapper.NewWithFieldLogger(log)
-}
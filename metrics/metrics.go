@@ -0,0 +1,65 @@
+// Package metrics holds the Prometheus metrics of the game server. They are registered
+// on the default registry, which pitaya serves on /metrics along with its own.
+package metrics
+
+import (
+	"errors"
+	"time"
+
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+// Namespace prefixes the names of the game metrics.
+const Namespace = "zecrey"
+
+var (
+	TickDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "tick_duration_seconds",
+		Help:      "Time taken by a tick of the game loop.",
+		Buckets:   prometheus.ExponentialBuckets(0.0001, 2, 12), // 100µs to 200ms
+	})
+	BroadcastBytes = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "broadcast_bytes",
+		Help:      "Payload size of the messages broadcast to a group, by route.",
+		Buckets:   prometheus.ExponentialBuckets(64, 4, 8), // 64B to 1MB
+	}, []string{"route"})
+	ChatMessages = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "chat_messages_total",
+		Help:      "Chat messages delivered, by source.",
+	}, []string{"source"})
+	Votes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "votes_total",
+		Help:      "Votes for a camp, each of which adds a ball to the round.",
+	}, []string{"camp"})
+	DBCallDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "db_call_duration_seconds",
+		Help:      "Time taken by a call to a database accessor.",
+		Buckets:   prometheus.ExponentialBuckets(0.0005, 2, 12), // 500µs to 1s
+	}, []string{"accessor", "method"})
+	DBCallErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "db_call_errors_total",
+		Help:      "Calls to a database accessor that failed, a missing record is not a failure.",
+	}, []string{"accessor", "method"})
+)
+
+func init() {
+	prometheus.MustRegister(TickDuration, BroadcastBytes, ChatMessages, Votes, DBCallDuration, DBCallErrors)
+}
+
+// ObserveDB records a database call, it is a db.Observer.
+func ObserveDB(accessor, method string, d time.Duration, err error) {
+	DBCallDuration.WithLabelValues(accessor, method).Observe(d.Seconds())
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		DBCallErrors.WithLabelValues(accessor, method).Inc()
+	}
+}
+
+var _ db.Observer = ObserveDB
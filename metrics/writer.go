@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	writerQueued  = prometheus.NewDesc(Namespace+"_writer_queued", "Writes waiting in the queue of the database writer.", nil, nil)
+	writerPending = prometheus.NewDesc(Namespace+"_writer_pending", "Writes waiting for the database to come back.", nil, nil)
+	writerWritten = prometheus.NewDesc(Namespace+"_writer_written_total", "Writes flushed to the database.", nil, nil)
+	writerDropped = prometheus.NewDesc(Namespace+"_writer_dropped_total", "Writes dropped because the queue was full.", nil, nil)
+	writerFailed  = prometheus.NewDesc(Namespace+"_writer_failed_total", "Writes that failed for good.", nil, nil)
+	writerLag     = prometheus.NewDesc(Namespace+"_writer_lag_seconds", "How long the last flushed batch waited in the queue.", nil, nil)
+)
+
+// WriterCollector reads the stats of a database writer when scraped.
+type WriterCollector struct {
+	writer *db.Writer
+}
+
+func NewWriterCollector(w *db.Writer) *WriterCollector {
+	return &WriterCollector{writer: w}
+}
+
+func (c *WriterCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, d := range []*prometheus.Desc{writerQueued, writerPending, writerWritten, writerDropped, writerFailed, writerLag} {
+		ch <- d
+	}
+}
+
+func (c *WriterCollector) Collect(ch chan<- prometheus.Metric) {
+	s := c.writer.Stats()
+	ch <- prometheus.MustNewConstMetric(writerQueued, prometheus.GaugeValue, float64(s.Queued))
+	ch <- prometheus.MustNewConstMetric(writerPending, prometheus.GaugeValue, float64(s.Pending))
+	ch <- prometheus.MustNewConstMetric(writerWritten, prometheus.CounterValue, float64(s.Written))
+	ch <- prometheus.MustNewConstMetric(writerDropped, prometheus.CounterValue, float64(s.Dropped))
+	ch <- prometheus.MustNewConstMetric(writerFailed, prometheus.CounterValue, float64(s.Failed))
+	ch <- prometheus.MustNewConstMetric(writerLag, prometheus.GaugeValue, s.Lag.Seconds())
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+
+	cfg "github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/db"
+)
+
+func migrate(conf *cfg.Config, args []string) {
+	database := db.NewClient(conf.Database)
+
+	var err error
+	switch {
+	case len(args) == 0 || args[0] == "up":
+		if err = database.Migrate(); err == nil {
+			err = database.Seed()
+		}
+	case args[0] == "down":
+		steps := 1
+		if len(args) > 1 {
+			if steps, err = strconv.Atoi(args[1]); err != nil || steps < 1 {
+				fmt.Fprintf(os.Stderr, "invalid steps %q\n", args[1])
+				os.Exit(2)
+			}
+		}
+		err = database.Rollback(steps)
+	case args[0] == "status":
+		var states []db.MigrationState
+		if states, err = database.MigrationStatus(); err == nil {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(states)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q\n", args[0])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func seed(conf *cfg.Config) {
+	if err := db.NewClient(conf.Database).Seed(); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
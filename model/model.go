@@ -35,17 +35,40 @@ type Camp struct {
 
 type Game struct {
 	gorm.Model
-	StartTime time.Time `json:"start_time"`
-	EndTime   time.Time `json:"end_time"`
-	WinnerID  uint8     `json:"winner_id"`
-	Winner    Camp      `gorm:"foreignKey:WinnerID" json:"winner"`
+	StartTime time.Time  `json:"start_time"`
+	EndTime   time.Time  `json:"end_time"`
+	WinnerID  uint8      `json:"winner_id"`
+	Winner    Camp       `gorm:"foreignKey:WinnerID" json:"winner"`
+	Settled   bool       `gorm:"not null;default:false;index" json:"settled"`
+	SettledAt *time.Time `json:"settled_at"`
 }
 
 type Message struct {
 	gorm.Model
-	Message  string `json:"message"`
-	PlayerID uint64 `json:"player_id"`
-	Player   Player `gorm:"foreignKey:PlayerID;references:PlayerID" json:"player"`
+	Message  string   `json:"message"`
+	PlayerID uint64   `json:"player_id"`
+	Player   Player   `gorm:"foreignKey:PlayerID;references:PlayerID" json:"player"`
+	Mentions []uint64 `gorm:"-" json:"mentions,omitempty"`
+}
+
+type DirectMessage struct {
+	gorm.Model
+	Message string `json:"message"`
+	FromID  uint64 `gorm:"index" json:"from_id"`
+	ToID    uint64 `gorm:"index" json:"to_id"`
+	From    Player `gorm:"foreignKey:FromID;references:PlayerID" json:"from"`
+	To      Player `gorm:"foreignKey:ToID;references:PlayerID" json:"to"`
+}
+
+// Checkpoint is the latest snapshot of an unfinished round, used to resume it after a restart.
+type Checkpoint struct {
+	GameID    uint   `gorm:"primarykey;autoIncrement:false" json:"game_id"`
+	Game      Game   `gorm:"foreignKey:GameID" json:"-"`
+	Frame     uint32 `json:"frame"`
+	Remaining int64  `json:"remaining"` // milliseconds left in the round
+	State     []byte `json:"state"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
 }
 
 const (
@@ -0,0 +1,103 @@
+// Package role defines what a session may do. A session is a spectator until it
+// joins the chat as a player, or authenticates as a moderator or a caster.
+package role
+
+import (
+	"crypto/subtle"
+	"errors"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/topfreegames/pitaya/v2/session"
+)
+
+type Role string
+
+const (
+	Spectator   Role = "spectator"   // watches the game and cheers
+	Participant Role = "participant" // a chat player, chats and votes for a camp
+	Moderator   Role = "moderator"   // operates the rounds and moderates the chat
+	Caster      Role = "caster"      // comments the game, gets enriched frames
+
+	sessionKey = "role"
+)
+
+// Capability is something a role may be allowed to do.
+type Capability int
+
+const (
+	Chat Capability = iota
+	Vote
+	Cheer
+	Moderate
+	CasterFeed
+)
+
+var (
+	ErrForbidden   = errors.New("role not allowed")
+	ErrUnknownRole = errors.New("unknown role")
+
+	capabilities = map[Role][]Capability{
+		Spectator:   {Cheer},
+		Participant: {Chat, Vote, Cheer},
+		Moderator:   {Chat, Moderate, CasterFeed},
+		Caster:      {Chat, CasterFeed},
+	}
+)
+
+// Parse returns the role named s.
+func Parse(s string) (Role, error) {
+	r := Role(s)
+	if _, ok := capabilities[r]; !ok {
+		return "", ErrUnknownRole
+	}
+	return r, nil
+}
+
+// Can reports whether r has capability c.
+func (r Role) Can(c Capability) bool {
+	for _, v := range capabilities[r] {
+		if v == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Claim checks that a session may take r with token: moderators need the admin
+// token and casters the caster token, participants get their role joining the chat.
+func Claim(cfg *config.Config, r Role, token string) error {
+	switch r {
+	case Spectator:
+		return nil
+	case Moderator:
+		if validToken(token, cfg.AdminToken) {
+			return nil
+		}
+	case Caster:
+		if validToken(token, cfg.CasterToken) {
+			return nil
+		}
+	case Participant:
+	default:
+		return ErrUnknownRole
+	}
+	return ErrForbidden
+}
+
+// validToken compares in constant time, an empty want disables the role.
+func validToken(token, want string) bool {
+	return want != "" && subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1
+}
+
+// Of returns the role of s, Spectator if it has none.
+func Of(s session.Session) Role {
+	if s == nil || !s.HasKey(sessionKey) {
+		return Spectator
+	}
+	return Role(s.String(sessionKey))
+}
+
+// Set gives r to s.
+func Set(s session.Session, r Role) error {
+	return s.Set(sessionKey, string(r))
+}
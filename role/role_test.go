@@ -0,0 +1,75 @@
+package role
+
+import (
+	"testing"
+
+	"github.com/COAOX/zecrey_warrior/config"
+)
+
+func TestClaim(t *testing.T) {
+	cfg := &config.Config{AdminToken: "admin", CasterToken: "caster"}
+	noTokens := &config.Config{}
+	tests := []struct {
+		name  string
+		cfg   *config.Config
+		role  Role
+		token string
+		want  error
+	}{
+		{"spectator", cfg, Spectator, "", nil},
+		{"moderator", cfg, Moderator, "admin", nil},
+		{"moderator with the caster token", cfg, Moderator, "caster", ErrForbidden},
+		{"moderator without a token", cfg, Moderator, "", ErrForbidden},
+		{"caster", cfg, Caster, "caster", nil},
+		{"caster with the admin token", cfg, Caster, "admin", ErrForbidden},
+		// an empty token disables the role, even for an empty token
+		{"moderator disabled", noTokens, Moderator, "", ErrForbidden},
+		{"caster disabled", noTokens, Caster, "", ErrForbidden},
+		// participants get their role joining the chat, it can't be claimed
+		{"participant", cfg, Participant, "", ErrForbidden},
+		{"participant with the admin token", cfg, Participant, "admin", ErrForbidden},
+		{"unknown role", cfg, Role("root"), "admin", ErrUnknownRole},
+		{"no role", cfg, Role(""), "", ErrUnknownRole},
+	}
+	for _, tt := range tests {
+		if err := Claim(tt.cfg, tt.role, tt.token); err != tt.want {
+			t.Errorf("%s: Claim(%q) = %v, want %v", tt.name, tt.role, err, tt.want)
+		}
+	}
+}
+
+func TestCan(t *testing.T) {
+	all := []Capability{Chat, Vote, Cheer, Moderate, CasterFeed}
+	tests := []struct {
+		role Role
+		can  []Capability
+	}{
+		{Spectator, []Capability{Cheer}},
+		{Participant, []Capability{Chat, Vote, Cheer}},
+		{Moderator, []Capability{Chat, Moderate, CasterFeed}},
+		{Caster, []Capability{Chat, CasterFeed}},
+		{Role("root"), nil},
+	}
+	for _, tt := range tests {
+		for _, c := range all {
+			want := false
+			for _, v := range tt.can {
+				want = want || v == c
+			}
+			if got := tt.role.Can(c); got != want {
+				t.Errorf("%s.Can(%d) = %v, want %v", tt.role, c, got, want)
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	for _, r := range []Role{Spectator, Participant, Moderator, Caster} {
+		if got, err := Parse(string(r)); err != nil || got != r {
+			t.Errorf("Parse(%q) = %q, %v", r, got, err)
+		}
+	}
+	if _, err := Parse("root"); err != ErrUnknownRole {
+		t.Errorf("Parse(root) = %v, want %v", err, ErrUnknownRole)
+	}
+}
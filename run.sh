@@ -4,6 +4,11 @@ set -e
 set -v
 
 export ENV="local"
+# the admin api and moderator role are disabled without a token
+# export ZECREY_ADMIN_TOKEN="change-me"
+
+# the server doesn't migrate the database itself
+go run . --config=./config/local.json migrate up
 
 # run service
 go run . --config=./config/local.json
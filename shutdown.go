@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	cfg "github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/game"
+	"github.com/COAOX/zecrey_warrior/health"
+	"github.com/COAOX/zecrey_warrior/logging"
+	"github.com/topfreegames/pitaya/v2"
+	"github.com/topfreegames/pitaya/v2/session"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultShutdownTimeout = 30 * time.Second
+	defaultShutdownNotice  = 3 * time.Second
+	httpShutdownTimeout    = 5 * time.Second
+
+	// drainCheckpointShare is the part of the shutdown timeout kept for the checkpoint
+	// when the round doesn't end in time.
+	drainCheckpointShare = 5
+
+	maintenanceMessage = "The server is going down for maintenance"
+)
+
+// drainingPool is the session pool given to pitaya. Pitaya stops on SIGINT and SIGTERM,
+// or on Shutdown, and closes the sessions first thing: the pool runs the graceful
+// shutdown before, so that the clients are told about it.
+type drainingPool struct {
+	session.SessionPool
+	once     sync.Once
+	shutdown func() // set before the app starts
+}
+
+func (p *drainingPool) CloseAll() {
+	p.once.Do(func() {
+		if p.shutdown != nil {
+			p.shutdown()
+		}
+	})
+	p.SessionPool.CloseAll()
+}
+
+// handleSignals cancels stop on a second SIGINT or SIGTERM, to cut the graceful shutdown
+// short. Pitaya handles the first one. It must be called before the app starts.
+func handleSignals(stop context.CancelFunc) {
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		s := <-sig
+		zap.L().Info("got signal, shutting down gracefully, send it again to stop now", zap.Stringer("signal", s))
+		s = <-sig
+		zap.L().Warn("got signal again, stopping now", zap.Stringer("signal", s))
+		stop()
+	}()
+}
+
+// shutdown stops the server gracefully: it isn't ready any more, then it drains.
+func shutdown(ctx context.Context, app pitaya.Pitaya, conf *cfg.Config, g *game.Game, checker *health.Checker) {
+	checker.Drain()
+	drain(ctx, app, conf, g)
+}
+
+// drain warns the clients, then lets the round end or checkpoints it, within conf.Shutdown.Timeout
+// or until parent is done.
+func drain(parent context.Context, app pitaya.Pitaya, conf *cfg.Config, g *game.Game) {
+	timeout, notice := defaultShutdownTimeout, defaultShutdownNotice
+	if conf.Shutdown.Timeout > 0 {
+		timeout = time.Duration(conf.Shutdown.Timeout) * time.Second
+	}
+	if conf.Shutdown.Notice > 0 {
+		notice = time.Duration(conf.Shutdown.Notice) * time.Second
+	}
+	if notice > timeout {
+		notice = timeout
+	}
+	start := time.Now()
+	ctx, cancel := context.WithDeadline(parent, start.Add(timeout))
+	defer cancel()
+
+	m := game.Maintenance{Message: maintenanceMessage, Deadline: start.Add(timeout).Unix()}
+	for _, room := range []string{cfg.GameRoomName, cfg.ChatRoomName} {
+		if err := app.GroupBroadcast(ctx, conf.FrontendType, room, "onMaintenance", m); err != nil {
+			zap.L().Error("broadcast maintenance notice failed", logging.Room(room), zap.Error(err))
+		}
+	}
+
+	ended := false
+	if conf.Shutdown.WaitRound {
+		waitCtx, cancelWait := context.WithDeadline(ctx, start.Add(timeout-timeout/drainCheckpointShare))
+		err := g.WaitRoundEnd(waitCtx)
+		cancelWait()
+		if err != nil {
+			zap.L().Warn("round did not end before the shutdown timeout, checkpointing it", logging.GameID(g.GetGameID()), zap.Error(err))
+		} else {
+			ended = true
+		}
+	}
+	if !ended {
+		if err := g.Checkpoint(ctx); err != nil {
+			zap.L().Error("checkpoint round failed", logging.GameID(g.GetGameID()), zap.Error(err))
+		}
+	}
+
+	// give the clients the time to show the notice
+	if wait := notice - time.Since(start); wait > 0 {
+		t := time.NewTimer(wait)
+		defer t.Stop()
+		select {
+		case <-t.C:
+		case <-ctx.Done():
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	cfg "github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/game"
+	"github.com/COAOX/zecrey_warrior/health"
+	"github.com/topfreegames/pitaya/v2"
+	"github.com/topfreegames/pitaya/v2/session"
+)
+
+type recordingPool struct {
+	session.SessionPool
+	steps *[]string
+}
+
+func (p recordingPool) CloseAll() {
+	*p.steps = append(*p.steps, "close sessions")
+}
+
+// testApp records the shutdown steps as the clients see them.
+type testApp struct {
+	pitaya.Pitaya
+	broadcast func(room string)
+}
+
+func (a testApp) GroupBroadcast(ctx context.Context, frontendType, groupName, route string, v interface{}) error {
+	a.broadcast(groupName + " " + route)
+	return nil
+}
+
+func TestDrainingPool(t *testing.T) {
+	var steps []string
+	pool := &drainingPool{
+		SessionPool: recordingPool{SessionPool: session.NewSessionPool(), steps: &steps},
+		shutdown:    func() { steps = append(steps, "shutdown") },
+	}
+	pool.CloseAll()
+	pool.CloseAll()
+	want := []string{"shutdown", "close sessions", "close sessions"}
+	if len(steps) != len(want) {
+		t.Fatalf("steps %v, want %v", steps, want)
+	}
+	for i := range want {
+		if steps[i] != want[i] {
+			t.Fatalf("steps %v, want %v", steps, want)
+		}
+	}
+}
+
+func TestShutdown(t *testing.T) {
+	for _, tt := range []struct {
+		name       string
+		timeout    int
+		waitRound  bool
+		endRound   bool
+		checkpoint bool
+	}{
+		{"checkpoint", 1, false, false, true},
+		{"round running, not ending in time", 1, true, false, true},
+		// nothing to wait for, the notice is the only wait
+		{"between rounds", 10, true, true, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			conf := &cfg.Config{FPS: 30, ItemFrameChance: 1 << 30, GameDuration: 600, GameRoundInterval: 600, FrontendType: "test"}
+			conf.Shutdown = cfg.ShutdownConfig{Timeout: tt.timeout, Notice: 1, WaitRound: tt.waitRound}
+			d := db.NewMemoryClient()
+			writer := db.NewWriter(d, db.WriterConfig{})
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			g := game.NewGame(ctx, conf, d, writer, func(context.Context) {}, func(context.Context) {}, func(camp game.Camp, votes int32) {})
+			g.Start()
+			if tt.endRound {
+				if err := g.EndRound(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			checker := health.New(d, writer, g, func(string) bool { return false })
+			for checker.Status().Tick.LastTick.IsZero() {
+				time.Sleep(10 * time.Millisecond)
+			}
+
+			checkpointed := func() bool {
+				flushCtx, flushCancel := context.WithTimeout(context.Background(), time.Second)
+				defer flushCancel()
+				if err := writer.Flush(flushCtx); err != nil {
+					t.Fatal(err)
+				}
+				_, err := d.Checkpoint.LatestUnfinished()
+				return err == nil
+			}
+			var steps []string
+			app := testApp{broadcast: func(room string) {
+				// the server stops being ready, then the clients are warned, then the round is saved
+				if s := checker.Status().Status; s != health.StatusDraining {
+					t.Errorf("health status %s when the clients are warned, want %s", s, health.StatusDraining)
+				}
+				if checkpointed() {
+					t.Error("the round was checkpointed before the clients were warned")
+				}
+				steps = append(steps, room)
+			}}
+
+			start := time.Now()
+			shutdown(context.Background(), app, conf, g, checker)
+			if d := time.Since(start); d > 3*time.Second {
+				t.Errorf("shutdown took %v, more than the notice and the round's wait", d)
+			}
+			if len(steps) != 2 || steps[0] != cfg.GameRoomName+" onMaintenance" || steps[1] != cfg.ChatRoomName+" onMaintenance" {
+				t.Errorf("broadcasts %v, want the maintenance notice in the game then the chat room", steps)
+			}
+			if got := checkpointed(); got != tt.checkpoint {
+				t.Errorf("checkpointed %v, want %v", got, tt.checkpoint)
+			}
+		})
+	}
+}
@@ -0,0 +1,83 @@
+package sim
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/game"
+)
+
+type CampStats struct {
+	Camp        string  `json:"camp"`
+	Wins        int     `json:"wins"`
+	WinRate     float64 `json:"win_rate"`
+	AvgCoverage float64 `json:"avg_coverage"` // fraction of the map owned at the end of a round
+	AvgVotes    float64 `json:"avg_votes"`
+}
+
+type Report struct {
+	Rounds          int         `json:"rounds"`
+	TickRate        int         `json:"tick_rate"`
+	AvgRoundFrames  float64     `json:"avg_round_frames"`
+	AvgRoundSeconds float64     `json:"avg_round_seconds"`
+	Camps           []CampStats `json:"camps"`
+}
+
+func newReport(cfg *config.Config, rounds []round) Report {
+	r := Report{Rounds: len(rounds), TickRate: cfg.SimulationRate()}
+	n := float64(len(rounds))
+	for _, c := range Camps {
+		s := CampStats{Camp: game.CampTagMap[c]}
+		for _, rd := range rounds {
+			if rd.winner == c {
+				s.Wins++
+			}
+			total := 0
+			for _, cells := range rd.cells {
+				total += cells
+			}
+			s.AvgCoverage += float64(rd.cells[c]) / float64(total) / n
+			s.AvgVotes += float64(rd.votes[c]) / n
+		}
+		s.WinRate = float64(s.Wins) / n
+		r.Camps = append(r.Camps, s)
+	}
+	for _, rd := range rounds {
+		r.AvgRoundFrames += float64(rd.frames) / n
+	}
+	r.AvgRoundSeconds = r.AvgRoundFrames / float64(r.TickRate)
+	return r
+}
+
+func (r Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteCSV writes one row per camp, the round length columns are the same on every row.
+func (r Report) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"camp", "wins", "win_rate", "avg_coverage", "avg_votes", "rounds", "avg_round_frames", "avg_round_seconds"})
+	for _, c := range r.Camps {
+		cw.Write([]string{
+			c.Camp,
+			strconv.Itoa(c.Wins),
+			formatFloat(c.WinRate),
+			formatFloat(c.AvgCoverage),
+			formatFloat(c.AvgVotes),
+			strconv.Itoa(r.Rounds),
+			formatFloat(r.AvgRoundFrames),
+			formatFloat(r.AvgRoundSeconds),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 4, 64)
+}
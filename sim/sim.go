@@ -0,0 +1,158 @@
+// Package sim runs rounds of the game headless, without pitaya, a database or
+// real time, to compare how the camps fare against each other.
+package sim
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/db"
+	"github.com/COAOX/zecrey_warrior/game"
+)
+
+// Camps are the camps players vote for, in report order.
+var Camps = []game.Camp{game.BTC, game.ETH, game.BNB, game.AVAX, game.MATIC}
+
+type Config struct {
+	Rounds  int
+	Players int // balls joining each round
+	// Weights are the relative chances of a player voting for each camp, nil votes uniformly.
+	Weights map[game.Camp]float64
+	// JoinWindow is the fraction of the round, from its start, during which players join.
+	JoinWindow float64
+	// StopCoverage ends a round early once a camp owns this fraction of the map, 0 never does.
+	StopCoverage float64
+	Seed         int64
+}
+
+// ParseWeights parses camp weights such as "BTC=3,ETH=1", camps left out get no vote.
+func ParseWeights(s string) (map[game.Camp]float64, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	weights := map[game.Camp]float64{}
+	for _, kv := range strings.Split(s, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(kv), "=")
+		camp, known := game.CampTagMapReverse[strings.ToUpper(name)]
+		if !ok || !known || camp == game.Empty {
+			return nil, fmt.Errorf("invalid camp weight %q", kv)
+		}
+		w, err := strconv.ParseFloat(value, 64)
+		if err != nil || w < 0 {
+			return nil, fmt.Errorf("invalid camp weight %q", kv)
+		}
+		weights[camp] = w
+	}
+	return weights, nil
+}
+
+type round struct {
+	winner game.Camp
+	frames int
+	cells  map[game.Camp]int
+	votes  map[game.Camp]int32
+}
+
+// Run simulates sc.Rounds rounds of cfg.GameDuration seconds at cfg.TickRate ticks per
+// second, as fast as possible. Items drop with cfg.ItemFrameChance as in the server.
+func Run(cfg *config.Config, sc Config) (Report, error) {
+	if sc.Rounds <= 0 {
+		return Report{}, fmt.Errorf("rounds must be positive, got %d", sc.Rounds)
+	}
+	if sc.JoinWindow <= 0 || sc.JoinWindow > 1 {
+		return Report{}, fmt.Errorf("join window must be in (0, 1], got %v", sc.JoinWindow)
+	}
+	rnd := rand.New(rand.NewSource(sc.Seed))
+	pick, err := picker(sc.Weights, rnd)
+	if err != nil {
+		return Report{}, err
+	}
+
+	d := db.NewMemoryClient()
+	writer := db.NewWriter(d, db.WriterConfig{})
+	defer writer.Close(context.Background())
+	g := game.NewGame(context.Background(), cfg, d, writer, func(context.Context) {}, func(context.Context) {}, func(game.Camp, int32) {})
+	g.Seed(sc.Seed)
+
+	frames := cfg.GameDuration * cfg.SimulationRate()
+	rounds := make([]round, 0, sc.Rounds)
+	for i := 0; i < sc.Rounds; i++ {
+		if i > 0 {
+			g.Reset()
+		}
+		g.GameStatus = game.GameRunning
+		rounds = append(rounds, play(g, frames, sc, pick, rnd))
+	}
+	return newReport(cfg, rounds), nil
+}
+
+// play runs one round and returns how it ended.
+func play(g *game.Game, frames int, sc Config, pick func() game.Camp, rnd *rand.Rand) round {
+	joins := make([]int, sc.Players)
+	for i := range joins {
+		joins[i] = rnd.Intn(int(float64(frames)*sc.JoinWindow) + 1)
+	}
+	sort.Ints(joins)
+
+	stop := int(sc.StopCoverage * float64(len(g.Map.Cells)))
+	r := round{frames: frames}
+	next := 0
+	for frame := 0; frame < frames; frame++ {
+		for ; next < len(joins) && joins[next] <= frame; next++ {
+			g.AddPlayer(uint64(next+1), "", pick(), nil)
+		}
+		g.Step()
+		if stop > 0 && maxCells(g.Map.Cells) >= stop {
+			r.frames = frame + 1
+			break
+		}
+	}
+
+	r.winner, _ = g.GetWinner()
+	r.votes = g.CampVotes()
+	r.cells = map[game.Camp]int{}
+	for _, c := range g.Map.Cells {
+		r.cells[c]++
+	}
+	return r
+}
+
+// picker returns a function drawing camps according to weights.
+func picker(weights map[game.Camp]float64, rnd *rand.Rand) (func() game.Camp, error) {
+	if weights == nil {
+		return func() game.Camp { return Camps[rnd.Intn(len(Camps))] }, nil
+	}
+	total := 0.0
+	cumulative := make([]float64, len(Camps))
+	for i, c := range Camps {
+		total += weights[c]
+		cumulative[i] = total
+	}
+	if total == 0 {
+		return nil, fmt.Errorf("camp weights must not all be 0")
+	}
+	return func() game.Camp {
+		v := rnd.Float64() * total
+		return Camps[sort.Search(len(cumulative), func(i int) bool { return cumulative[i] > v })]
+	}, nil
+}
+
+// maxCells returns how many cells the leading camp owns.
+func maxCells(cells []game.Camp) int {
+	count := map[game.Camp]int{}
+	max := 0
+	for _, c := range cells {
+		if c == game.Empty {
+			continue
+		}
+		if count[c]++; count[c] > max {
+			max = count[c]
+		}
+	}
+	return max
+}
@@ -0,0 +1,145 @@
+package sim
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/game"
+)
+
+func testConfig() *config.Config {
+	return &config.Config{FPS: 30, TickRate: 30, ItemFrameChance: 100, GameDuration: 5, FrontendType: "test"}
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestRunDeterministic(t *testing.T) {
+	sc := Config{Rounds: 3, Players: 20, JoinWindow: 0.5, Seed: 7}
+	first, err := Run(testConfig(), sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := Run(testConfig(), sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("two runs with the same seed differ:\n%+v\n%+v", first, second)
+	}
+
+	if first.Rounds != sc.Rounds || first.TickRate != 30 {
+		t.Errorf("rounds %d tick rate %d, want %d 30", first.Rounds, first.TickRate, sc.Rounds)
+	}
+	if first.AvgRoundFrames != 150 || first.AvgRoundSeconds != 5 {
+		t.Errorf("avg round %v frames %v seconds, want the full 150 frames, 5 seconds", first.AvgRoundFrames, first.AvgRoundSeconds)
+	}
+	if len(first.Camps) != len(Camps) {
+		t.Fatalf("%d camps in the report, want %d", len(first.Camps), len(Camps))
+	}
+	wins, winRate, coverage, votes := 0, 0.0, 0.0, 0.0
+	for i, c := range first.Camps {
+		if c.Camp != game.CampTagMap[Camps[i]] {
+			t.Errorf("camp %d is %s, want %s", i, c.Camp, game.CampTagMap[Camps[i]])
+		}
+		wins += c.Wins
+		winRate += c.WinRate
+		coverage += c.AvgCoverage
+		votes += c.AvgVotes
+	}
+	if wins != sc.Rounds || !near(winRate, 1) {
+		t.Errorf("%d wins, win rates sum to %v, want a winner per round", wins, winRate)
+	}
+	if coverage <= 0 || coverage > 1+1e-9 {
+		t.Errorf("coverages sum to %v, want a fraction of the map", coverage)
+	}
+	if !near(votes, float64(sc.Players)) {
+		t.Errorf("votes sum to %v a round, want one per player, %d", votes, sc.Players)
+	}
+}
+
+func TestRunStopCoverage(t *testing.T) {
+	// every player votes BTC: it takes over the map and the rounds end early
+	cfg := testConfig()
+	cfg.GameDuration = 60
+	sc := Config{Rounds: 2, Players: 10, JoinWindow: 0.1, Weights: map[game.Camp]float64{game.BTC: 1}, StopCoverage: 0.02, Seed: 1}
+	r, err := Run(cfg, sc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.AvgRoundFrames >= 1800 || !near(r.AvgRoundSeconds, r.AvgRoundFrames/30) {
+		t.Errorf("avg round %v frames %v seconds, want an early end", r.AvgRoundFrames, r.AvgRoundSeconds)
+	}
+	// the players still to join when the round ends don't vote
+	if btc := r.Camps[0]; btc.Wins != 2 || btc.WinRate != 1 || btc.AvgCoverage < sc.StopCoverage || btc.AvgVotes == 0 || btc.AvgVotes > 10 {
+		t.Errorf("BTC %+v, want every round and vote, and the stop coverage", btc)
+	}
+	for _, c := range r.Camps[1:] {
+		if c.Wins != 0 || c.AvgVotes != 0 {
+			t.Errorf("%s %+v, want no win nor vote", c.Camp, c)
+		}
+	}
+}
+
+func TestRunInvalid(t *testing.T) {
+	for _, sc := range []Config{
+		{Rounds: 0, JoinWindow: 0.5},
+		{Rounds: 1, JoinWindow: 0},
+		{Rounds: 1, JoinWindow: 1.5},
+		{Rounds: 1, JoinWindow: 0.5, Weights: map[game.Camp]float64{game.BTC: 0}},
+	} {
+		if _, err := Run(testConfig(), sc); err == nil {
+			t.Errorf("%+v: no error", sc)
+		}
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	cells := func(btc, eth, empty int) map[game.Camp]int {
+		return map[game.Camp]int{game.BTC: btc, game.ETH: eth, game.Empty: empty}
+	}
+	r := newReport(testConfig(), []round{
+		{winner: game.BTC, frames: 90, cells: cells(6, 2, 2), votes: map[game.Camp]int32{game.BTC: 3, game.ETH: 1}},
+		{winner: game.ETH, frames: 150, cells: cells(1, 3, 6), votes: map[game.Camp]int32{game.ETH: 2}},
+	})
+	if r.Rounds != 2 || r.AvgRoundFrames != 120 || r.AvgRoundSeconds != 4 {
+		t.Errorf("rounds %d, avg %v frames %v seconds, want 2, 120, 4", r.Rounds, r.AvgRoundFrames, r.AvgRoundSeconds)
+	}
+	for _, want := range []CampStats{
+		{Camp: "BTC", Wins: 1, WinRate: 0.5, AvgCoverage: 0.35, AvgVotes: 1.5},
+		{Camp: "ETH", Wins: 1, WinRate: 0.5, AvgCoverage: 0.25, AvgVotes: 1.5},
+		{Camp: "BNB"},
+	} {
+		var got CampStats
+		for _, c := range r.Camps {
+			if c.Camp == want.Camp {
+				got = c
+			}
+		}
+		if got.Camp != want.Camp || got.Wins != want.Wins || !near(got.WinRate, want.WinRate) ||
+			!near(got.AvgCoverage, want.AvgCoverage) || !near(got.AvgVotes, want.AvgVotes) {
+			t.Errorf("%s: got %+v, want %+v", want.Camp, got, want)
+		}
+	}
+}
+
+func TestParseWeights(t *testing.T) {
+	w, err := ParseWeights(" btc=3, ETH=0.5 ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[game.Camp]float64{game.BTC: 3, game.ETH: 0.5}; !reflect.DeepEqual(w, want) {
+		t.Errorf("weights %v, want %v", w, want)
+	}
+	if w, err := ParseWeights(""); w != nil || err != nil {
+		t.Errorf("empty weights: %v %v, want uniform votes", w, err)
+	}
+	for _, s := range []string{"BTC", "BTC=x", "BTC=-1", "DOGE=1", "EMPTY=1"} {
+		if _, err := ParseWeights(s); err == nil {
+			t.Errorf("%q: no error", s)
+		}
+	}
+}
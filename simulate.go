@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	cfg "github.com/COAOX/zecrey_warrior/config"
+	"github.com/COAOX/zecrey_warrior/sim"
+)
+
+func simulate(conf *cfg.Config, args []string) {
+	fs := flag.NewFlagSet("simulate", flag.ExitOnError)
+	rounds := fs.Int("rounds", 100, "number of rounds to simulate")
+	players := fs.Int("players", 50, "balls joining each round")
+	weights := fs.String("weights", "", "relative vote weight of each camp, e.g. BTC=3,ETH=1 (default uniform)")
+	joinWindow := fs.Float64("join-window", 0.5, "fraction of the round during which players join")
+	stopCoverage := fs.Float64("stop-coverage", 0, "end a round once a camp owns this fraction of the map, 0 never")
+	seed := fs.Int64("seed", 1, "random seed")
+	format := fs.String("format", "json", "output format, json or csv")
+	out := fs.String("out", "", "output file (default stdout)")
+	fs.Parse(args)
+
+	var write func(sim.Report, io.Writer) error
+	switch *format {
+	case "json":
+		write = sim.Report.WriteJSON
+	case "csv":
+		write = sim.Report.WriteCSV
+	default:
+		fmt.Fprintf(os.Stderr, "unknown format %q, want json or csv\n", *format)
+		os.Exit(2)
+	}
+	w, err := sim.ParseWeights(*weights)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	report, err := sim.Run(conf, sim.Config{
+		Rounds:       *rounds,
+		Players:      *players,
+		Weights:      w,
+		JoinWindow:   *joinWindow,
+		StopCoverage: *stopCoverage,
+		Seed:         *seed,
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	var dst io.Writer = os.Stdout
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		dst = f
+	}
+	if err := write(report, dst); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}